@@ -0,0 +1,130 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"botik/internal/util"
+)
+
+const actionPrefix = "al"
+
+// Action is a command from inline keyboard button of alert message.
+type Action struct {
+	Cmd string
+	ID  string
+	Arg string
+}
+
+// Encode returns callback data for the action, telegram allows up to 64 bytes.
+func (act *Action) Encode() string {
+	return strings.Join([]string{actionPrefix, act.Cmd, act.ID, act.Arg}, "|")
+}
+
+func ParseAction(data string) (*Action, bool) {
+	parts := strings.Split(data, "|")
+
+	if len(parts) != 4 || parts[0] != actionPrefix || parts[1] == "" || parts[2] == "" {
+		return nil, false
+	}
+
+	return &Action{Cmd: parts[1], ID: parts[2], Arg: parts[3]}, true
+}
+
+// Action applies action made by user, it returns notification to replace the original message with
+// and short status text.
+func (a *AlertManager) Action(act *Action, user string) (*Notification, string, error) {
+	ar := a.FindAlert(act.ID)
+
+	if ar == nil {
+		return nil, "", fmt.Errorf("alert is not found")
+	}
+
+	tpl := "alert_bad"
+	status := "ok"
+
+	switch act.Cmd {
+	case "mute":
+		var d time.Duration
+
+		if act.Arg != "" && act.Arg != "0" {
+			var err error
+			if d, err = util.ParseDuration(act.Arg); err != nil {
+				return nil, "", err
+			}
+		}
+
+		s, err := a.MuteAlert(ar, d, user)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if s.EndsAt.IsZero() {
+			status = "muted"
+		} else {
+			status = "muted until " + s.EndsAt.Format(util.TIME_FMT)
+		}
+
+	case "unmute":
+		a.UnmuteAlert(ar, user)
+		status = "unmuted"
+
+	case "ack":
+		if err := a.Ack(ar, user); err != nil {
+			return nil, "", err
+		}
+
+		status = "acknowledged"
+
+	case "details":
+		tpl = "details"
+
+	case "summary":
+
+	default:
+		return nil, "", fmt.Errorf("unknown action %s", act.Cmd)
+	}
+
+	a.logger.Info(fmt.Sprintf("action %s %s by %s", act.Cmd, act.ID, user))
+
+	n, err := a.notification(ar, tpl)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return n, status, nil
+}
+
+// IsFiringTemplate reports if the message made with template is about firing alert.
+func IsFiringTemplate(tpl string) bool {
+	return tpl == "alert_bad" || tpl == "reminder" || tpl == "details" || tpl == "escalation"
+}
+
+func (a *AlertManager) FindAlert(id string) *AlertRec {
+	var res *AlertRec
+
+	a.Range(func(ar *AlertRec) bool {
+		if ar.Alert().ID == id {
+			res = ar
+			return false
+		}
+
+		return true
+	})
+
+	return res
+}
+
+// activeSilence returns one of the silences muting the alert.
+func (a *AlertManager) activeSilence(al *Alert) *Silence {
+	now := a.now()
+
+	for _, s := range a.Silences() {
+		if s.Active(now) && s.Matches(al) {
+			return s
+		}
+	}
+
+	return nil
+}
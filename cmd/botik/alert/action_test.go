@@ -0,0 +1,48 @@
+package alert
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionEncode(t *testing.T) {
+	act := &Action{Cmd: "mute", ID: "12345678901234567890", Arg: "1d"}
+	data := act.Encode()
+
+	assert.LessOrEqual(t, len(data), 64)
+
+	act2, ok := ParseAction(data)
+	require.True(t, ok)
+	assert.Equal(t, act, act2)
+
+	for _, s := range []string{"", "al|mute", "xx|mute|1|", "al||1|", "al|mute||1h"} {
+		_, ok := ParseAction(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestActionMute(t *testing.T) {
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "alert", State: "firing"}, "url1", time.Now())
+	am.alerts.Store("url1", ar)
+
+	n, _, err := am.Action(&Action{Cmd: "mute", ID: "1", Arg: "1h"}, "user")
+	require.NoError(t, err)
+	require.NotNil(t, n.Silence)
+	assert.Equal(t, "user", n.Silence.CreatedBy)
+	assert.Contains(t, n.Text, "muted until")
+	assert.True(t, ar.IsMuted())
+
+	n, _, err = am.Action(&Action{Cmd: "unmute", ID: "1"}, "user")
+	require.NoError(t, err)
+	assert.Nil(t, n.Silence)
+	assert.False(t, ar.IsMuted())
+
+	_, _, err = am.Action(&Action{Cmd: "mute", ID: "2"}, "user")
+	assert.Error(t, err)
+}
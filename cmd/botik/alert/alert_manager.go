@@ -1,6 +1,8 @@
 package alert
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	_ "embed"
 	"encoding/json"
@@ -8,6 +10,7 @@ import (
 	"html/template"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,16 +25,67 @@ type AlertManager struct {
 	chIn     chan string
 	client   *http.Client
 	tpl      *template.Template
-	notifier func(msg string)
+	notifier Notifier
+	conf     *Config
+	now      func() time.Time
+	store    Store
+	saved    []byte
+	hist     History
+	pruned   time.Time
+	silences map[string]*Silence
+	smx      sync.RWMutex
+	groups   map[string]*alertGroup
+	gmx      sync.Mutex
+	flaps    map[string]*flapState
+	fmx      sync.Mutex
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
 }
 
-func NewManager(logger *slog.Logger, notifier func(msg string)) *AlertManager {
+// Notification is an alert event with rendered message text, it goes to notifier.
+type Notification struct {
+	Alert    *Alert
+	Source   string
+	Url      string
+	Silence  *Silence
+	Template string
+	Text     string
+	// Edit is set when notification must replace text of already sent messages
+	Edit []Delivery
+	// ReplyTo is set when notification must be sent as a reply to these messages instead of routing
+	ReplyTo []Delivery
+	// Receivers are set when notification must go to them instead of routing
+	Receivers []string
+	AckedBy   string
+	// GroupKey, GroupLabels and Alerts are set for alert group notification
+	GroupKey    string
+	GroupLabels map[string]string
+	Alerts      []*Alert
+	// Delivered is called by notifier for a message sent after it has returned, it is set when sent
+	// messages are kept to be edited or replied later
+	Delivered func(Delivery)
+}
+
+// Delivery is a telegram message sent to a chat.
+type Delivery struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int   `json:"message_id"`
+}
+
+// Notifier sends notification and returns messages it has sent. Notifier which doesn't wait for
+// sending returns nil and reports sent messages to Notification.Delivered.
+type Notifier func(n *Notification) []Delivery
+
+func NewManager(logger *slog.Logger, notifier Notifier) *AlertManager {
 	tmpl, err := template.New("").ParseFS(alerts, "template/*")
 
 	if err != nil {
 		panic(err)
 	}
 
+	conf := new(Config)
+	conf.setDefaults()
+
 	return &AlertManager{
 		logger:   logger,
 		alerts:   sync.Map{},
@@ -39,12 +93,72 @@ func NewManager(logger *slog.Logger, notifier func(msg string)) *AlertManager {
 		client:   &http.Client{Timeout: time.Second * 3},
 		tpl:      tmpl,
 		notifier: notifier,
+		conf:     conf,
+		now:      time.Now,
+		store:    NewMemoryStore(),
+		hist:     NewMemoryHistory(),
+		silences: make(map[string]*Silence),
+		groups:   make(map[string]*alertGroup),
+		flaps:    make(map[string]*flapState),
 	}
 }
 
-func (a *AlertManager) Start() {
-	go a.alertProcessor()
-	go a.urlAdder()
+func (a *AlertManager) Configure(conf *Config) error {
+	conf.setDefaults()
+
+	if err := conf.Reminders.Validate(); err != nil {
+		return err
+	}
+
+	a.conf = conf
+
+	return nil
+}
+
+// SetClock replaces time source, for tests.
+func (a *AlertManager) SetClock(now func() time.Time) {
+	a.now = now
+}
+
+func (a *AlertManager) SetStore(store Store) {
+	a.store = store
+}
+
+func (a *AlertManager) SetHistory(h History) {
+	a.hist = h
+}
+
+// Start restores saved state and runs alert processing till ctx is done or Stop is called.
+func (a *AlertManager) Start(ctx context.Context) {
+	a.restore()
+
+	ctx, a.cancel = context.WithCancel(ctx)
+
+	p := newPoller(a)
+	p.start(ctx, &a.wg)
+
+	a.wg.Add(2)
+
+	go func() {
+		defer a.wg.Done()
+		a.alertProcessor(ctx, p)
+	}()
+
+	go func() {
+		defer a.wg.Done()
+		a.urlAdder(ctx, p)
+	}()
+}
+
+// Stop stops alert processing and saves state.
+func (a *AlertManager) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+
+	a.wg.Wait()
+	a.save()
+	a.logger.Info("alert manager stopped")
 }
 
 func (a *AlertManager) AddUrl(url string) {
@@ -56,26 +170,42 @@ func (a *AlertManager) AddUrl(url string) {
 	}
 }
 
-func (a *AlertManager) urlAdder() {
-	for url := range a.chIn {
-		if _, ok := a.alerts.Load(url); ok {
+func (a *AlertManager) urlAdder(ctx context.Context, p *poller) {
+	for {
+		var url string
+
+		select {
+		case <-ctx.Done():
 			return
+		case url = <-a.chIn:
+		}
+
+		if _, ok := a.alerts.Load(url); ok {
+			continue
 		}
 
 		a.logger.Info("new alert: " + url)
 
-		alertInfo, err := a.fetchAlertInfo(url)
+		alertInfo, err := p.fetch(ctx, url)
 
 		if err != nil {
 			a.logger.Error("error getting alert", "error", err)
-			return
+			continue
 		}
 
-		if alertInfo != nil {
-			ar := NewAlertRec(alertInfo, url)
-			a.alerts.Store(url, ar)
-			a.logger.Info(ar.String())
+		if alertInfo == nil {
+			continue
 		}
+
+		ar := NewAlertRec(alertInfo, url, a.now())
+
+		if _, loaded := a.alerts.LoadOrStore(url, ar); loaded {
+			continue
+		}
+
+		a.logger.Info(ar.String())
+		a.record(EventNew, ar, "")
+		a.transition(ar)
 	}
 }
 
@@ -89,51 +219,162 @@ func (a *AlertManager) Range(f func(a *AlertRec) bool) {
 	})
 }
 
-func (a *AlertManager) alertProcessor() {
+func (a *AlertManager) alertProcessor(ctx context.Context, p *poller) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
 	for {
-		a.alerts.Range(func(key, value interface{}) bool {
-			if alertRec, ok := value.(*AlertRec); ok {
-				alertInfo, err := a.fetchAlertInfo(key.(string))
+		select {
+		case <-ctx.Done():
+			return
+		case res := <-p.results:
+			a.pollDone(p, res)
+		case <-ticker.C:
+			a.tick(p)
+		}
+	}
+}
 
-				if err != nil {
-					a.logger.Error(fmt.Sprintf("error getting alert %v", key), "error", err.Error())
-					return true
-				}
+// tick does periodic work: expires pushed alerts, schedules polls, sends notifications and saves state.
+func (a *AlertManager) tick(p *poller) {
+	a.expireSilences()
+	a.applySilences()
 
-				if alertInfo == nil {
-					a.logger.Info(fmt.Sprintf("remove %s alert (404)", key))
-					a.alerts.Delete(key)
-					a.notify(alertRec, "alert_good")
+	if a.flapDetection() {
+		a.checkFlaps()
+	}
 
-					return true
-				}
+	a.alerts.Range(func(key, value interface{}) bool {
+		alertRec, ok := value.(*AlertRec)
 
-				a.update(alertRec, alertInfo)
+		if !ok {
+			a.logger.Error(fmt.Sprintf("invalid value: %v", value))
+			return true
+		}
 
-				if alertRec.NeedToNotify() {
-					if alertRec.IsNew() {
-						a.notify(alertRec, "alert_bad")
-					} else {
-						a.notify(alertRec, "reminder")
-					}
+		if alertRec.IsPushed() {
+			if alertRec.Expired(a.now()) {
+				if _, ok := a.alerts.LoadAndDelete(key); ok {
+					a.logger.Info(fmt.Sprintf("remove %s alert (expired)", key))
+					a.resolve(alertRec)
 				}
-			} else {
-				a.logger.Error(fmt.Sprintf("invalid value: %v", value))
+
+				return true
 			}
+		} else {
+			p.dispatch(alertRec.Key(), alertRec, a.now())
+		}
 
-			return true
-		})
+		a.check(alertRec)
+
+		return true
+	})
+
+	a.flushGroups()
+	a.pruneHistory()
+	a.save()
+}
+
+// check sends new alert notification or reminder if it is time to.
+func (a *AlertManager) check(alertRec *AlertRec) {
+	if a.IsFlapping(alertRec.Alert().ID) {
+		if alertRec.IsNew() {
+			alertRec.Notified(a.now())
+		}
 
-		time.Sleep(time.Second)
+		return
+	}
+
+	if alertRec.NeedToNotify(a.conf.Reminders, a.now()) {
+		if alertRec.IsNew() && a.grouping() {
+			a.groupAdd(alertRec)
+		} else if alertRec.IsNew() {
+			a.notify(alertRec, "alert_bad")
+		} else {
+			a.notify(alertRec, "reminder")
+		}
 	}
+
+	a.escalate(alertRec)
 }
 
-func (a *AlertManager) fetchAlertInfo(alertUrl string) (*Alert, error) {
-	resp, err := a.client.Get(alertUrl)
+func (a *AlertManager) restore() {
+	state, err := a.store.Load()
+
+	if err != nil {
+		a.logger.Error("can't load alerts state", "error", err)
+		return
+	}
+
+	for _, s := range state.Silences {
+		if err := a.AddSilence(s); err != nil {
+			a.logger.Error("can't restore silence "+s.ID, "error", err)
+		}
+	}
+
+	for _, s := range state.Alerts {
+		ar := RestoreAlertRec(s)
+
+		if ar.Key() == "" || s.Alert == nil {
+			continue
+		}
+
+		a.alerts.Store(ar.Key(), ar)
+		a.restoreFlaps(ar, s.FlapTransitions)
+		a.logger.Info("restored " + ar.String())
+	}
+}
+
+// save writes current state to the store if it has changed since the last save.
+func (a *AlertManager) save() {
+	state := new(State)
+
+	a.Range(func(ar *AlertRec) bool {
+		s := ar.Snapshot()
+		s.FlapTransitions = a.flapTransitions(ar.Alert().ID)
+		state.Alerts = append(state.Alerts, s)
+
+		return true
+	})
+
+	sort.Slice(state.Alerts, func(i, j int) bool {
+		return state.Alerts[i].Key < state.Alerts[j].Key
+	})
+
+	state.Silences = a.Silences()
+
+	b, err := json.Marshal(state)
+
+	if err != nil {
+		a.logger.Error("can't marshal alerts state", "error", err)
+		return
+	}
+
+	if a.saved != nil && bytes.Equal(b, a.saved) {
+		return
+	}
+
+	if err := a.store.Save(state); err != nil {
+		a.logger.Error("can't save alerts state", "error", err)
+		return
+	}
+
+	a.saved = b
+}
+
+func (a *AlertManager) fetchAlertInfo(ctx context.Context, alertUrl string) (*Alert, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, alertUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error getting url %s: %s", alertUrl, err.Error())
 	}
 
+	defer resp.Body.Close()
+
 	if resp.StatusCode == 404 {
 		return nil, nil
 	}
@@ -142,7 +383,6 @@ func (a *AlertManager) fetchAlertInfo(alertUrl string) (*Alert, error) {
 		return nil, fmt.Errorf("error getting url %s: status %d", alertUrl, resp.StatusCode)
 	}
 
-	defer resp.Body.Close()
 	al := new(Alert)
 	m := json.NewDecoder(resp.Body)
 	if err := m.Decode(al); err != nil {
@@ -152,38 +392,198 @@ func (a *AlertManager) fetchAlertInfo(alertUrl string) (*Alert, error) {
 	return al, nil
 }
 
-func (a *AlertManager) update(rec *AlertRec, alert *Alert) {
+// update sets new alert info, it returns true if alert state is changed.
+func (a *AlertManager) update(rec *AlertRec, alert *Alert) bool {
 	old := rec.SetAlert(alert)
 
 	if old == nil || alert == nil {
-		return
+		return false
 	}
 
 	if old.State != alert.State {
 		a.logger.Info(fmt.Sprintf("alert %s %s %s -> %s", old.ID, old.Name, old.State, alert.State))
+		a.record(EventState, rec, "")
+		a.transition(rec)
+		return true
+	}
+
+	return false
+}
+
+// resolve edits sent messages of gone alert or sends a new message if there are none.
+func (a *AlertManager) resolve(rec *AlertRec) {
+	a.record(EventResolve, rec, "")
+	a.transition(rec)
+
+	if a.IsFlapping(rec.Alert().ID) {
+		return
+	}
+
+	if a.groupResolve(rec) {
+		return
+	}
+
+	ds := rec.Deliveries()
+
+	if len(ds) == 0 {
+		a.notify(rec, "alert_good")
+		return
+	}
+
+	n, err := a.notification(rec, "resolved")
+	if err != nil {
+		return
+	}
+
+	n.Edit = ds
+	a.notifier(n)
+
+	if a.conf.ReplyResolved && !rec.IsMuted() {
+		if n, err := a.notification(rec, "alert_good"); err == nil {
+			n.ReplyTo = ds
+			a.notifier(n)
+		}
+	}
+}
+
+// edit updates sent messages after alert state change.
+func (a *AlertManager) edit(rec *AlertRec) {
+	ds := rec.Deliveries()
+
+	if len(ds) == 0 || a.IsFlapping(rec.Alert().ID) {
+		return
+	}
+
+	tpl := "resolved"
+	if rec.State() == "firing" {
+		tpl = "alert_bad"
+	}
+
+	if n, err := a.notification(rec, tpl); err == nil {
+		n.Edit = ds
+		a.notifier(n)
 	}
 }
 
 func (a *AlertManager) notify(rec *AlertRec, tpl string) {
-	if rec.IsMuted() {
+	if rec.IsMuted() || a.IsSilenced(rec.Alert()) {
 		return
 	}
 
-	if msg, err := a.getMsg(rec.Alert(), tpl); err == nil {
-		rec.Notified()
-		a.notifier(msg)
-	} else {
-		a.logger.Error("error in template", "error", err)
+	if tpl == "reminder" {
+		a.record(EventReminder, rec, "")
+	}
+
+	if n, err := a.notification(rec, tpl); err == nil {
+		rec.Notified(a.now())
+		a.deliver(rec, n)
+	}
+}
+
+// deliver sends notification of the alert, messages are added to its deliveries when they are sent.
+func (a *AlertManager) deliver(rec *AlertRec, n *Notification) {
+	n.Delivered = func(d Delivery) {
+		rec.AddDeliveries([]Delivery{d})
 	}
+
+	rec.AddDeliveries(a.notifier(n))
+}
+
+func (a *AlertManager) notification(rec *AlertRec, tpl string) (*Notification, error) {
+	n := &Notification{
+		Alert:    rec.Alert(),
+		Source:   rec.Source(),
+		Url:      rec.Url(),
+		Template: tpl,
+		AckedBy:  rec.AckedBy(),
+	}
+
+	n.Silence = a.activeSilence(n.Alert)
+
+	msg, err := a.render(tpl, map[string]any{
+		"alert":       n.Alert,
+		"silence":     n.Silence,
+		"duration":    rec.Duration(a.now()).Round(time.Second).String(),
+		"acked_by":    n.AckedBy,
+		"tier":        len(rec.Pages()),
+		"active":      a.isActive(rec),
+		"transitions": a.flapCount(n.Alert.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	n.Text = msg
+
+	return n, nil
+}
+
+// isActive reports if alert is not resolved.
+func (a *AlertManager) isActive(rec *AlertRec) bool {
+	v, ok := a.alerts.Load(rec.Key())
+
+	return ok && v == rec
 }
 
 func (a *AlertManager) getMsg(alert *Alert, tpl_name string) (string, error) {
+	return a.render(tpl_name, map[string]any{"alert": alert})
+}
+
+func (a *AlertManager) render(tpl_name string, data map[string]any) (string, error) {
 	sb := new(strings.Builder)
 
-	if err := a.tpl.ExecuteTemplate(sb, tpl_name, map[string]any{"alert": alert}); err != nil {
+	if err := a.tpl.ExecuteTemplate(sb, tpl_name, data); err != nil {
 		a.logger.Error("error in template", "error", err)
 		return "", err
 	}
 
 	return sb.String(), nil
 }
+
+// Labels returns alert labels with alert name, group and source for routing.
+func (n *Notification) Labels() map[string]string {
+	res := map[string]string{"source": n.Source}
+
+	if n.Alert == nil {
+		for k, v := range commonLabels(n.Alerts) {
+			res[k] = v
+		}
+
+		for k, v := range n.GroupLabels {
+			res[k] = v
+		}
+
+		return res
+	}
+
+	for k, v := range n.Alert.Labels {
+		res[k] = v
+	}
+
+	res["alertname"] = n.Alert.Field("alertname")
+	res["group_id"] = n.Alert.GroupID
+
+	return res
+}
+
+func commonLabels(alerts []*Alert) map[string]string {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	res := make(map[string]string)
+
+	for k, v := range alerts[0].Labels {
+		res[k] = v
+	}
+
+	for _, al := range alerts[1:] {
+		for k, v := range res {
+			if al.Labels[k] != v {
+				delete(res, k)
+			}
+		}
+	}
+
+	return res
+}
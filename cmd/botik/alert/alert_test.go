@@ -7,11 +7,12 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAlertBad(t *testing.T) {
-	am := NewManager(slog.Default(), func(msg string) {
-
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		return nil
 	})
 
 	al1 := &Alert{
@@ -44,7 +45,7 @@ func TestAlertBad(t *testing.T) {
 		ActiveAt: time.Now(),
 	}
 
-	for _, tpl := range []string{"alert_bad", "alert_good", "inactive", "reminder"} {
+	for _, tpl := range []string{"alert_bad", "alert_good", "inactive", "reminder", "details", "resolved", "escalation", "flapping", "flapping_stopped"} {
 		t.Run("alert_"+tpl, func(t *testing.T) {
 			s, err := am.getMsg(al1, tpl)
 
@@ -61,3 +62,36 @@ func TestAlertBad(t *testing.T) {
 	}
 
 }
+
+func TestResolveEdit(t *testing.T) {
+	var sent []*Notification
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n)
+
+		if len(n.Edit) > 0 {
+			return nil
+		}
+
+		return []Delivery{{ChatID: 1, MessageID: len(sent)}, {ChatID: 2, MessageID: 0}}
+	})
+	am.Configure(&Config{ReplyResolved: true})
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "alert", State: "firing", ActiveAt: time.Now().Add(-time.Hour)}, "url1", time.Now())
+
+	am.notify(ar, "alert_bad")
+	assert.Equal(t, []Delivery{{ChatID: 1, MessageID: 1}}, ar.Deliveries())
+
+	assert.True(t, am.update(ar, &Alert{ID: "1", Name: "alert", State: "inactive"}))
+	am.edit(ar)
+	assert.Equal(t, "resolved", sent[1].Template)
+	assert.Equal(t, ar.Deliveries(), sent[1].Edit)
+
+	am.resolve(ar)
+	require.Len(t, sent, 4)
+	assert.Equal(t, "resolved", sent[2].Template)
+	assert.Contains(t, sent[2].Text, "<s>")
+	assert.Equal(t, ar.Deliveries(), sent[2].Edit)
+	assert.Equal(t, "alert_good", sent[3].Template)
+	assert.Equal(t, ar.Deliveries(), sent[3].ReplyTo)
+}
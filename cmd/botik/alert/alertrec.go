@@ -6,8 +6,6 @@ import (
 	"time"
 )
 
-const notifyDelay = time.Hour * 3
-
 type Alert struct {
 	ID          string            `json:"id"`
 	Name        string            `json:"name"`
@@ -44,26 +42,44 @@ func (a *Alert) Severity() string {
 type AlertRec struct {
 	created    time.Time
 	alert      *Alert
+	key        string
+	source     string
 	url        string
+	pushed     bool
+	endsAt     time.Time
 	lastNotify time.Time
+	reminders  int
 	muted      bool
+	flapping   bool
+	ackedBy    string
+	ackedAt    time.Time
+	pages      []Page
 	new        bool
+	deliveries []Delivery
 	mx         sync.RWMutex
 }
 
 type AlertRecDTO struct {
 	Alert      *Alert    `json:"alert,omitempty"`
 	Url        string    `json:"url,omitempty"`
+	Source     string    `json:"source,omitempty"`
 	Created    time.Time `json:"created"`
 	LastNotify time.Time `json:"last_notify"`
 	Muted      bool      `json:"muted,omitempty"`
+	Flapping   bool      `json:"flapping,omitempty"`
+	AckedBy    string    `json:"acked_by,omitempty"`
+	AckedAt    time.Time `json:"acked_at,omitempty"`
+	Pages      []Page    `json:"pages,omitempty"`
 }
 
-func NewAlertRec(alert *Alert, url string) *AlertRec {
+// NewAlertRec makes record for polled alert, created is the manager clock time.
+func NewAlertRec(alert *Alert, url string, created time.Time) *AlertRec {
 	return &AlertRec{
 		alert:      alert,
+		key:        url,
+		source:     SourceVmalert,
 		url:        url,
-		created:    time.Now(),
+		created:    created,
 		lastNotify: time.Time{},
 		muted:      false,
 		new:        true,
@@ -71,6 +87,55 @@ func NewAlertRec(alert *Alert, url string) *AlertRec {
 	}
 }
 
+// NewPushedAlertRec makes record for alert that is pushed to us and is not polled.
+func NewPushedAlertRec(alert *Alert, key string, source string, url string, endsAt time.Time, created time.Time) *AlertRec {
+	return &AlertRec{
+		alert:      alert,
+		key:        key,
+		source:     source,
+		url:        url,
+		pushed:     true,
+		endsAt:     endsAt,
+		created:    created,
+		lastNotify: time.Time{},
+		muted:      false,
+		new:        true,
+		mx:         sync.RWMutex{},
+	}
+}
+
+func RestoreAlertRec(s *AlertRecState) *AlertRec {
+	key, source := s.Key, s.Source
+
+	if key == "" {
+		key = s.Url
+	}
+
+	if source == "" {
+		source = SourceVmalert
+	}
+
+	return &AlertRec{
+		alert:      s.Alert,
+		key:        key,
+		source:     source,
+		url:        s.Url,
+		pushed:     s.Pushed,
+		endsAt:     s.EndsAt,
+		created:    s.Created,
+		lastNotify: s.LastNotify,
+		reminders:  s.Reminders,
+		muted:      s.Muted,
+		flapping:   s.Flapping,
+		ackedBy:    s.AckedBy,
+		ackedAt:    s.AckedAt,
+		pages:      s.Pages,
+		new:        s.New,
+		deliveries: s.Deliveries,
+		mx:         sync.RWMutex{},
+	}
+}
+
 func (a *AlertRec) Alert() *Alert {
 	a.mx.RLock()
 	defer a.mx.RUnlock()
@@ -99,25 +164,168 @@ func (a *AlertRec) SetAlert(alert *Alert) *Alert {
 	return old
 }
 
-func (a *AlertRec) Notified() *AlertRec {
+func (a *AlertRec) Notified(now time.Time) *AlertRec {
 	a.mx.Lock()
 	defer a.mx.Unlock()
 
-	a.lastNotify = time.Now()
+	if !a.new {
+		a.reminders++
+	}
+
+	a.lastNotify = now
 	a.new = false
 
 	return a
 }
 
-func (a *AlertRec) Mute() *AlertRec {
+func (a *AlertRec) SetMuted(muted bool) *AlertRec {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.muted = muted
+
+	return a
+}
+
+func (a *AlertRec) AddDeliveries(ds []Delivery) *AlertRec {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	for _, d := range ds {
+		if d.MessageID != 0 {
+			a.deliveries = append(a.deliveries[:len(a.deliveries):len(a.deliveries)], d)
+		}
+	}
+
+	return a
+}
+
+func (a *AlertRec) Deliveries() []Delivery {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.deliveries
+}
+
+// Duration returns how long alert is active at now.
+func (a *AlertRec) Duration(now time.Time) time.Duration {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	if a.alert != nil && !a.alert.ActiveAt.IsZero() {
+		return now.Sub(a.alert.ActiveAt)
+	}
+
+	return now.Sub(a.created)
+}
+
+func (a *AlertRec) Key() string {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.key
+}
+
+func (a *AlertRec) Source() string {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.source
+}
+
+// IsPushed reports if alert is pushed to us and must not be polled.
+func (a *AlertRec) IsPushed() bool {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.pushed
+}
+
+func (a *AlertRec) SetEndsAt(t time.Time) *AlertRec {
 	a.mx.Lock()
 	defer a.mx.Unlock()
 
-	a.muted = true
+	a.endsAt = t
 
 	return a
 }
 
+// Expired reports if pushed alert is not refreshed before its endsAt.
+func (a *AlertRec) Expired(now time.Time) bool {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.pushed && !a.endsAt.IsZero() && !a.endsAt.After(now)
+}
+
+// Ack marks alert as acknowledged by user, it returns false if it is already acknowledged.
+func (a *AlertRec) Ack(user string, now time.Time) bool {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if a.ackedBy != "" {
+		return false
+	}
+
+	a.ackedBy = user
+	a.ackedAt = now
+
+	return true
+}
+
+func (a *AlertRec) IsAcked() bool {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.ackedBy != ""
+}
+
+func (a *AlertRec) AckedBy() string {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.ackedBy
+}
+
+func (a *AlertRec) AddPage(p Page) *AlertRec {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.pages = append(a.pages[:len(a.pages):len(a.pages)], p)
+
+	return a
+}
+
+func (a *AlertRec) Pages() []Page {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.pages
+}
+
+func (a *AlertRec) Created() time.Time {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.created
+}
+
+func (a *AlertRec) SetFlapping(flapping bool) *AlertRec {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.flapping = flapping
+
+	return a
+}
+
+func (a *AlertRec) IsFlapping() bool {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return a.flapping
+}
+
 func (a *AlertRec) Url() string {
 	a.mx.RLock()
 	defer a.mx.RUnlock()
@@ -139,12 +347,41 @@ func (a *AlertRec) DTO() *AlertRecDTO {
 	return &AlertRecDTO{
 		Alert:      a.alert,
 		Url:        a.url,
+		Source:     a.source,
 		LastNotify: a.lastNotify,
 		Muted:      a.muted,
+		Flapping:   a.flapping,
+		AckedBy:    a.ackedBy,
+		AckedAt:    a.ackedAt,
+		Pages:      a.pages,
 		Created:    a.created,
 	}
 }
 
+func (a *AlertRec) Snapshot() *AlertRecState {
+	a.mx.RLock()
+	defer a.mx.RUnlock()
+
+	return &AlertRecState{
+		Key:        a.key,
+		Source:     a.source,
+		Url:        a.url,
+		Pushed:     a.pushed,
+		EndsAt:     a.endsAt,
+		Alert:      a.alert,
+		Created:    a.created,
+		LastNotify: a.lastNotify,
+		Reminders:  a.reminders,
+		Muted:      a.muted,
+		Flapping:   a.flapping,
+		AckedBy:    a.ackedBy,
+		AckedAt:    a.ackedAt,
+		Pages:      a.pages,
+		New:        a.new,
+		Deliveries: a.deliveries,
+	}
+}
+
 func (a *AlertRec) IsMuted() bool {
 	a.mx.RLock()
 	defer a.mx.RUnlock()
@@ -159,7 +396,7 @@ func (a *AlertRec) IsNew() bool {
 	return a.new
 }
 
-func (a *AlertRec) NeedToNotify() bool {
+func (a *AlertRec) NeedToNotify(p *ReminderPolicy, now time.Time) bool {
 	a.mx.RLock()
 	defer a.mx.RUnlock()
 
@@ -171,11 +408,11 @@ func (a *AlertRec) NeedToNotify() bool {
 		return true
 	}
 
-	if a.alert.Severity() == "critical" {
-		return time.Now().After(a.lastNotify.Add(notifyDelay))
+	if a.ackedBy != "" {
+		return false
 	}
 
-	return false
+	return p.Due(a.alert.Severity(), a.reminders, a.lastNotify, now)
 }
 
 func (a *AlertRec) String() string {
@@ -188,6 +425,14 @@ func (a *AlertRec) String() string {
 		res += "[muted] "
 	}
 
+	if a.flapping {
+		res += "[flapping] "
+	}
+
+	if a.ackedBy != "" {
+		res += "[acked by " + a.ackedBy + "] "
+	}
+
 	res += fmt.Sprintf("%s, state: %s, severity: %s", a.alert.Title(), a.alert.State, a.alert.Severity())
 
 	return res
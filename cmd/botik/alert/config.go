@@ -0,0 +1,52 @@
+package alert
+
+import "time"
+
+// Config is the alerts section of botik config.
+type Config struct {
+	// ReplyResolved sends a reply to the alert message when it is resolved, besides editing it.
+	ReplyResolved bool `koanf:"reply_resolved"`
+	// GroupBy are label names to group alerts by, no grouping if empty
+	GroupBy       []string      `koanf:"group_by"`
+	GroupWait     time.Duration `koanf:"group_wait"`
+	GroupInterval time.Duration `koanf:"group_interval"`
+	// Reminders is a reminder policy, DefaultReminderPolicy if empty
+	Reminders *ReminderPolicy `koanf:"reminders"`
+	// Escalation are tiers to notify about not acknowledged critical alerts
+	Escalation []EscalationTier `koanf:"escalation"`
+	// HistoryRetention is how long to keep alert history, forever if zero
+	HistoryRetention time.Duration `koanf:"history_retention"`
+	// alert is flapping if it changes state FlapThreshold times in FlapWindow, no flap detection if zero
+	FlapWindow    time.Duration `koanf:"flap_window"`
+	FlapThreshold int           `koanf:"flap_threshold"`
+	// PollInterval is how often to poll vmalert for every alert
+	PollInterval time.Duration `koanf:"poll_interval"`
+	// PollWorkers is a number of concurrent vmalert requests
+	PollWorkers int `koanf:"poll_workers"`
+	// PollRate is a max number of requests per second to one vmalert host
+	PollRate float64 `koanf:"poll_rate"`
+	// PollMaxBackoff is a max delay between polls of alert that can't be fetched
+	PollMaxBackoff time.Duration `koanf:"poll_max_backoff"`
+}
+
+func (c *Config) setDefaults() {
+	if c.Reminders == nil {
+		c.Reminders = DefaultReminderPolicy()
+	}
+
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+
+	if c.PollWorkers <= 0 {
+		c.PollWorkers = defaultPollWorkers
+	}
+
+	if c.PollRate <= 0 {
+		c.PollRate = defaultPollRate
+	}
+
+	if c.PollMaxBackoff < c.PollInterval {
+		c.PollMaxBackoff = max(defaultPollMaxBackoff, c.PollInterval)
+	}
+}
@@ -0,0 +1,63 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+)
+
+// EscalationTier are receivers to notify if critical alert is not acknowledged after some time since it is fired.
+type EscalationTier struct {
+	After     time.Duration `koanf:"after"`
+	Receivers []string      `koanf:"receivers"`
+}
+
+// Page is a record of escalation notification.
+type Page struct {
+	Tier      int       `json:"tier"`
+	Receivers []string  `json:"receivers"`
+	At        time.Time `json:"at"`
+}
+
+func (a *AlertManager) Ack(ar *AlertRec, user string) error {
+	if !ar.Ack(user, a.now()) {
+		return fmt.Errorf("alert is already acknowledged by %s", ar.AckedBy())
+	}
+
+	a.logger.Info(fmt.Sprintf("alert %s is acknowledged by %s", ar.Alert().ID, user))
+	a.record(EventAck, ar, user)
+
+	return nil
+}
+
+// escalate notifies the next tier if it is time to.
+func (a *AlertManager) escalate(rec *AlertRec) {
+	tiers := a.conf.Escalation
+
+	if len(tiers) == 0 || rec.IsAcked() || rec.IsMuted() || rec.IsNew() {
+		return
+	}
+
+	al := rec.Alert()
+
+	if al == nil || al.State != "firing" || al.Severity() != "critical" {
+		return
+	}
+
+	i := len(rec.Pages())
+
+	if i >= len(tiers) || a.now().Before(rec.Created().Add(tiers[i].After)) {
+		return
+	}
+
+	n, err := a.notification(rec, "escalation")
+	if err != nil {
+		return
+	}
+
+	n.Receivers = tiers[i].Receivers
+
+	a.logger.Info(fmt.Sprintf("escalate alert %s to tier %d", al.ID, i+1))
+	rec.AddPage(Page{Tier: i + 1, Receivers: tiers[i].Receivers, At: a.now()})
+	a.record(EventEscalation, rec, "")
+	a.deliver(rec, n)
+}
@@ -0,0 +1,62 @@
+package alert
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalation(t *testing.T) {
+	var sent []*Notification
+	// manager clock far from wall clock, alert age must be taken from it
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n)
+		return nil
+	})
+	am.SetClock(func() time.Time { return now })
+	require.NoError(t, am.Configure(&Config{
+		Reminders: &ReminderPolicy{},
+		Escalation: []EscalationTier{
+			{After: time.Minute * 15, Receivers: []string{"second"}},
+			{After: time.Hour, Receivers: []string{"third", "family"}},
+		},
+	}))
+
+	critical := NewAlertRec(&Alert{ID: "1", State: "firing", Labels: map[string]string{"severity": "critical"}}, "url1", now)
+	warning := NewAlertRec(&Alert{ID: "2", State: "firing", Labels: map[string]string{"severity": "warning"}}, "url2", now)
+
+	step := func(d time.Duration) {
+		now = now.Add(d)
+		am.check(critical)
+		am.check(warning)
+	}
+
+	step(0)
+	require.Len(t, sent, 2)
+
+	step(time.Minute * 14)
+	assert.Len(t, sent, 2)
+
+	step(time.Minute)
+	require.Len(t, sent, 3)
+	assert.Equal(t, "escalation", sent[2].Template)
+	assert.Equal(t, []string{"second"}, sent[2].Receivers)
+	assert.Equal(t, 1, critical.Pages()[0].Tier)
+
+	step(time.Minute * 10)
+	assert.Len(t, sent, 3)
+
+	require.NoError(t, am.Ack(critical, "user"))
+	assert.Error(t, am.Ack(critical, "user2"))
+	assert.Equal(t, "user", critical.AckedBy())
+
+	step(time.Hour)
+	assert.Len(t, sent, 3)
+	assert.Len(t, critical.Pages(), 1)
+	assert.Contains(t, critical.String(), "acked by user")
+}
@@ -0,0 +1,152 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+)
+
+// flapState keeps recent state transitions of alert with the same id.
+type flapState struct {
+	transitions []time.Time
+	flapping    bool
+	rec         *AlertRec
+}
+
+func (a *AlertManager) flapDetection() bool {
+	return a.conf.FlapThreshold > 0 && a.conf.FlapWindow > 0
+}
+
+// transition registers alert state change and sends flapping notification if alert starts flapping.
+func (a *AlertManager) transition(rec *AlertRec) {
+	if !a.flapDetection() {
+		return
+	}
+
+	al := rec.Alert()
+	if al == nil {
+		return
+	}
+
+	now := a.now()
+
+	a.fmx.Lock()
+	f, ok := a.flaps[al.ID]
+
+	if !ok {
+		f = new(flapState)
+		a.flaps[al.ID] = f
+	}
+
+	f.rec = rec
+	f.transitions = append(trimBefore(f.transitions, now.Add(-a.conf.FlapWindow)), now)
+
+	started := !f.flapping && len(f.transitions) >= a.conf.FlapThreshold
+	if started {
+		f.flapping = true
+	}
+
+	count := len(f.transitions)
+	a.fmx.Unlock()
+
+	if started {
+		a.logger.Info(fmt.Sprintf("alert %s %s is flapping, %d transitions", al.ID, al.Name, count))
+		a.notifyFlapping(rec, "flapping")
+	}
+}
+
+func (a *AlertManager) IsFlapping(id string) bool {
+	a.fmx.Lock()
+	defer a.fmx.Unlock()
+
+	f, ok := a.flaps[id]
+
+	return ok && f.flapping
+}
+
+func (a *AlertManager) flapCount(id string) int {
+	a.fmx.Lock()
+	defer a.fmx.Unlock()
+
+	if f, ok := a.flaps[id]; ok {
+		return len(f.transitions)
+	}
+
+	return 0
+}
+
+func (a *AlertManager) flapTransitions(id string) []time.Time {
+	a.fmx.Lock()
+	defer a.fmx.Unlock()
+
+	if f, ok := a.flaps[id]; ok {
+		return append([]time.Time(nil), f.transitions...)
+	}
+
+	return nil
+}
+
+// restoreFlaps brings back flap detection state of restored alert, so flapping alert stays quiet after restart.
+func (a *AlertManager) restoreFlaps(rec *AlertRec, transitions []time.Time) {
+	if len(transitions) == 0 && !rec.IsFlapping() {
+		return
+	}
+
+	a.fmx.Lock()
+	defer a.fmx.Unlock()
+
+	a.flaps[rec.Alert().ID] = &flapState{transitions: transitions, flapping: rec.IsFlapping(), rec: rec}
+}
+
+// checkFlaps forgets old transitions and tells about alerts that stopped flapping.
+func (a *AlertManager) checkFlaps() {
+	now := a.now()
+	var stable []*AlertRec
+
+	a.fmx.Lock()
+
+	for id, f := range a.flaps {
+		f.transitions = trimBefore(f.transitions, now.Add(-a.conf.FlapWindow))
+
+		if len(f.transitions) > 0 {
+			continue
+		}
+
+		if f.flapping {
+			stable = append(stable, f.rec)
+		}
+
+		delete(a.flaps, id)
+	}
+
+	a.fmx.Unlock()
+
+	for _, rec := range stable {
+		a.logger.Info(fmt.Sprintf("alert %s is not flapping now", rec.Alert().ID))
+		a.notifyFlapping(rec, "flapping_stopped")
+	}
+
+	a.Range(func(ar *AlertRec) bool {
+		ar.SetFlapping(a.IsFlapping(ar.Alert().ID))
+		return true
+	})
+}
+
+func (a *AlertManager) notifyFlapping(rec *AlertRec, tpl string) {
+	if a.IsSilenced(rec.Alert()) {
+		return
+	}
+
+	if n, err := a.notification(rec, tpl); err == nil {
+		a.notifier(n)
+	}
+}
+
+func trimBefore(ts []time.Time, t time.Time) []time.Time {
+	for i, t1 := range ts {
+		if !t1.Before(t) {
+			return ts[i:]
+		}
+	}
+
+	return nil
+}
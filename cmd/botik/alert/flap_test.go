@@ -0,0 +1,67 @@
+package alert
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlapping(t *testing.T) {
+	var sent []string
+	now := time.Now()
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n.Template)
+		return nil
+	})
+	am.SetClock(func() time.Time { return now })
+	require.NoError(t, am.Configure(&Config{FlapWindow: time.Hour, FlapThreshold: 4}))
+
+	fire := func() *AlertRec {
+		p := &PushedAlert{Labels: map[string]string{"alertname": "flappy"}, StartsAt: now}
+		am.Push(SourceVmalert, "", []*PushedAlert{p})
+		ar := am.FindAlert(p.ID())
+		am.check(ar)
+		return ar
+	}
+
+	resolve := func() {
+		p := &PushedAlert{Labels: map[string]string{"alertname": "flappy"}, Status: "resolved"}
+		am.Push(SourceVmalert, "", []*PushedAlert{p})
+	}
+
+	fire()
+	now = now.Add(time.Minute)
+	resolve()
+	now = now.Add(time.Minute)
+	ar := fire()
+
+	assert.Equal(t, []string{"alert_bad", "alert_good", "alert_bad"}, sent)
+	assert.False(t, ar.IsFlapping())
+
+	now = now.Add(time.Minute)
+	resolve()
+	assert.Equal(t, []string{"alert_bad", "alert_good", "alert_bad", "flapping"}, sent)
+
+	now = now.Add(time.Minute)
+	ar = fire()
+	am.checkFlaps()
+	assert.True(t, ar.IsFlapping())
+	assert.True(t, ar.DTO().Flapping)
+	assert.Contains(t, ar.String(), "[flapping]")
+
+	now = now.Add(time.Minute)
+	resolve()
+	now = now.Add(time.Minute)
+	fire()
+	assert.Len(t, sent, 4)
+
+	now = now.Add(2 * time.Hour)
+	am.checkFlaps()
+	require.Len(t, sent, 5)
+	assert.Equal(t, "flapping_stopped", sent[4])
+	assert.False(t, am.IsFlapping(ar.Alert().ID))
+}
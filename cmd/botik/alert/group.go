@@ -0,0 +1,255 @@
+package alert
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// alertGroup collects alerts with the same values of group_by labels, changes are sent as one message
+// not earlier than group_wait after the first alert and group_interval after the previous message.
+type alertGroup struct {
+	key        string
+	labels     map[string]string
+	members    map[string]*AlertRec
+	firing     []*AlertRec
+	resolved   []*AlertRec
+	sent       bool
+	next       time.Time
+	lastSent   time.Time
+	deliveries []Delivery
+}
+
+func (a *AlertManager) grouping() bool {
+	return len(a.conf.GroupBy) > 0
+}
+
+func (a *AlertManager) groupKey(al *Alert) (string, map[string]string) {
+	labels := make(map[string]string, len(a.conf.GroupBy))
+	parts := make([]string, len(a.conf.GroupBy))
+
+	for i, name := range a.conf.GroupBy {
+		v := al.Field(name)
+		labels[name] = v
+		parts[i] = name + "=" + v
+	}
+
+	return strings.Join(parts, ","), labels
+}
+
+// groupAdd puts firing alert to its group instead of sending a notification.
+func (a *AlertManager) groupAdd(rec *AlertRec) {
+	key, labels := a.groupKey(rec.Alert())
+	now := a.now()
+
+	a.gmx.Lock()
+	defer a.gmx.Unlock()
+
+	g, ok := a.groups[key]
+
+	if !ok {
+		g = &alertGroup{
+			key:     key,
+			labels:  labels,
+			members: make(map[string]*AlertRec),
+			next:    now.Add(a.conf.GroupWait),
+		}
+		a.groups[key] = g
+	}
+
+	g.members[rec.Key()] = rec
+	g.firing = append(g.firing, rec)
+	g.schedule(now, a.conf.GroupInterval)
+	rec.Notified(now)
+}
+
+// groupResolve puts resolved alert to its group, it returns false if alert was not grouped.
+func (a *AlertManager) groupResolve(rec *AlertRec) bool {
+	if !a.grouping() {
+		return false
+	}
+
+	key, _ := a.groupKey(rec.Alert())
+
+	a.gmx.Lock()
+	defer a.gmx.Unlock()
+
+	g, ok := a.groups[key]
+
+	if !ok || g.members[rec.Key()] == nil {
+		return false
+	}
+
+	delete(g.members, rec.Key())
+
+	for i, r := range g.firing {
+		if r == rec {
+			// never reported as firing, so don't report as resolved
+			g.firing = append(g.firing[:i], g.firing[i+1:]...)
+			return true
+		}
+	}
+
+	g.resolved = append(g.resolved, rec)
+	g.schedule(a.now(), a.conf.GroupInterval)
+
+	return true
+}
+
+func (g *alertGroup) schedule(now time.Time, interval time.Duration) {
+	if !g.sent || !g.next.IsZero() {
+		return
+	}
+
+	g.next = g.lastSent.Add(interval)
+
+	if g.next.Before(now) {
+		g.next = now
+	}
+}
+
+func (g *alertGroup) changed() bool {
+	return len(g.firing) > 0 || len(g.resolved) > 0
+}
+
+// flushGroups sends messages for groups with changes that are due.
+func (a *AlertManager) flushGroups() {
+	now := a.now()
+	var ready []*Notification
+
+	a.gmx.Lock()
+
+	for key, g := range a.groups {
+		if g.next.IsZero() || g.next.After(now) {
+			continue
+		}
+
+		g.next = time.Time{}
+
+		if !g.changed() {
+			if len(g.members) == 0 {
+				delete(a.groups, key)
+			}
+			continue
+		}
+
+		n, err := a.groupNotification(g)
+
+		if err == nil {
+			// updates go as replies to the first group message
+			n.ReplyTo = g.deliveries
+			ready = append(ready, n)
+		}
+
+		g.firing = nil
+		g.resolved = nil
+		g.sent = true
+		g.lastSent = now
+
+		if len(g.members) == 0 {
+			delete(a.groups, key)
+		}
+	}
+
+	a.gmx.Unlock()
+
+	for _, n := range ready {
+		if len(n.ReplyTo) > 0 {
+			a.notifier(n)
+			continue
+		}
+
+		key := n.GroupKey
+		n.Delivered = func(d Delivery) {
+			a.groupDelivered(key, []Delivery{d})
+		}
+
+		a.groupDelivered(key, a.notifier(n))
+	}
+}
+
+// groupDelivered keeps the first group messages, updates are sent as replies to them.
+func (a *AlertManager) groupDelivered(key string, ds []Delivery) {
+	a.gmx.Lock()
+	defer a.gmx.Unlock()
+
+	if g, ok := a.groups[key]; ok {
+		for _, d := range ds {
+			if d.MessageID != 0 {
+				g.deliveries = append(g.deliveries, d)
+			}
+		}
+	}
+}
+
+func (a *AlertManager) groupNotification(g *alertGroup) (*Notification, error) {
+	tpl := "group_update"
+	if !g.sent {
+		tpl = "group_bad"
+	}
+
+	active := sortedAlerts(g.members)
+
+	n := &Notification{
+		Source:      SourceVmalert,
+		Template:    tpl,
+		GroupKey:    g.key,
+		GroupLabels: g.labels,
+		Alerts:      active,
+	}
+
+	if len(g.firing) > 0 {
+		n.Source = g.firing[0].Source()
+	} else if len(g.resolved) > 0 {
+		n.Source = g.resolved[0].Source()
+	}
+
+	critical := false
+	for _, al := range active {
+		if al.Severity() == "critical" {
+			critical = true
+		}
+	}
+
+	msg, err := a.render(tpl, map[string]any{
+		"group":    g.labels,
+		"active":   active,
+		"firing":   recAlerts(g.firing),
+		"resolved": recAlerts(g.resolved),
+		"critical": critical,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	n.Text = msg
+
+	return n, nil
+}
+
+func sortedAlerts(m map[string]*AlertRec) []*Alert {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	res := make([]*Alert, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, m[k].Alert())
+	}
+
+	return res
+}
+
+func recAlerts(recs []*AlertRec) []*Alert {
+	res := make([]*Alert, len(recs))
+
+	for i, r := range recs {
+		res[i] = r.Alert()
+	}
+
+	return res
+}
@@ -0,0 +1,90 @@
+package alert
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	var sent []*Notification
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n)
+		return []Delivery{{ChatID: 1, MessageID: len(sent)}}
+	})
+	am.Configure(&Config{GroupBy: []string{"host"}, GroupWait: 0, GroupInterval: 0})
+
+	newRec := func(id, host, severity string) *AlertRec {
+		al := &Alert{ID: id, Name: "alert " + id, State: "firing", Labels: map[string]string{"host": host, "severity": severity}}
+		ar := NewAlertRec(al, "url"+id, time.Now())
+		am.alerts.Store(ar.Key(), ar)
+		return ar
+	}
+
+	r1 := newRec("1", "nas", "critical")
+	r2 := newRec("2", "nas", "warning")
+	r3 := newRec("3", "pi", "warning")
+
+	for _, r := range []*AlertRec{r1, r2, r3} {
+		am.check(r)
+		assert.False(t, r.IsNew())
+	}
+
+	assert.Empty(t, sent)
+
+	am.flushGroups()
+	require.Len(t, sent, 2)
+
+	for _, n := range sent {
+		assert.Equal(t, "group_bad", n.Template)
+
+		if n.GroupLabels["host"] == "nas" {
+			assert.Len(t, n.Alerts, 2)
+			assert.Contains(t, n.Text, "2 alerts")
+			assert.Equal(t, "nas", n.Labels()["host"])
+			assert.Empty(t, n.Labels()["severity"])
+		}
+	}
+
+	sent = nil
+	am.flushGroups()
+	assert.Empty(t, sent)
+
+	am.resolve(r1)
+	am.resolve(r2)
+	am.flushGroups()
+
+	require.Len(t, sent, 1)
+	assert.Equal(t, "group_update", sent[0].Template)
+	assert.Contains(t, sent[0].Text, "all resolved")
+	assert.NotEmpty(t, sent[0].ReplyTo)
+	assert.Len(t, am.groups, 1)
+}
+
+func TestGroupWait(t *testing.T) {
+	var sent []*Notification
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n)
+		return nil
+	})
+	am.Configure(&Config{GroupBy: []string{"alertname"}, GroupWait: time.Hour})
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "a", State: "firing"}, "url1", time.Now())
+	am.check(ar)
+	am.flushGroups()
+	assert.Empty(t, sent)
+
+	// resolved before group_wait, nothing to tell
+	am.resolve(ar)
+	assert.Empty(t, sent)
+
+	am.groups["alertname=a"].next = time.Now()
+	am.flushGroups()
+	assert.Empty(t, sent)
+	assert.Empty(t, am.groups)
+}
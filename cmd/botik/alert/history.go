@@ -0,0 +1,250 @@
+package alert
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"botik/internal/jsonfile"
+)
+
+const (
+	EventNew        = "new"
+	EventState      = "state"
+	EventReminder   = "reminder"
+	EventMute       = "mute"
+	EventUnmute     = "unmute"
+	EventAck        = "ack"
+	EventEscalation = "escalation"
+	EventResolve    = "resolve"
+)
+
+// Event is a record of alert lifecycle.
+type Event struct {
+	Time    time.Time         `json:"time"`
+	Type    string            `json:"type"`
+	AlertID string            `json:"alert_id"`
+	Name    string            `json:"name"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	State   string            `json:"state,omitempty"`
+	User    string            `json:"user,omitempty"`
+}
+
+// History is an append-only log of alert events.
+type History interface {
+	Append(e *Event) error
+	// Query returns events since the time with all labels matching, oldest first
+	Query(since time.Time, labels map[string]string) ([]*Event, error)
+	// Prune removes events older than the time
+	Prune(before time.Time) error
+}
+
+func (e *Event) Matches(since time.Time, labels map[string]string) bool {
+	if e.Time.Before(since) {
+		return false
+	}
+
+	for k, v := range labels {
+		if k == "alertname" && e.Name == v {
+			continue
+		}
+
+		if e.Labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+type MemoryHistory struct {
+	events []*Event
+	mx     sync.RWMutex
+}
+
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{}
+}
+
+func (m *MemoryHistory) Append(e *Event) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.events = append(m.events, e)
+
+	return nil
+}
+
+func (m *MemoryHistory) Query(since time.Time, labels map[string]string) ([]*Event, error) {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	res := make([]*Event, 0)
+
+	for _, e := range m.events {
+		if e.Matches(since, labels) {
+			res = append(res, e)
+		}
+	}
+
+	return res, nil
+}
+
+func (m *MemoryHistory) Prune(before time.Time) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	i := sort.Search(len(m.events), func(i int) bool {
+		return !m.events[i].Time.Before(before)
+	})
+
+	m.events = m.events[i:]
+
+	return nil
+}
+
+// FileHistory keeps events in a file, one json per line.
+type FileHistory struct {
+	log *jsonfile.Log[*Event]
+}
+
+func NewFileHistory(path string) *FileHistory {
+	return &FileHistory{log: jsonfile.NewLog[*Event](path, 0o644)}
+}
+
+func (f *FileHistory) Append(e *Event) error {
+	return f.log.Append(e)
+}
+
+func (f *FileHistory) Query(since time.Time, labels map[string]string) ([]*Event, error) {
+	res := make([]*Event, 0)
+
+	err := f.log.Read(func(e *Event) {
+		if e.Matches(since, labels) {
+			res = append(res, e)
+		}
+	})
+
+	return res, err
+}
+
+func (f *FileHistory) Prune(before time.Time) error {
+	return f.log.Keep(func(e *Event) bool {
+		return !e.Time.Before(before)
+	})
+}
+
+// Summary is a summary of alert history.
+type Summary struct {
+	Since      time.Time      `json:"since"`
+	Counts     map[string]int `json:"counts"`
+	Top        []*AlertStat   `json:"top"`
+	FiringTime time.Duration  `json:"firing_time"`
+}
+
+type AlertStat struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Transitions int           `json:"transitions"`
+	FiringTime  time.Duration `json:"firing_time"`
+
+	firingSince time.Time
+}
+
+// Summarize counts events by type, transitions and firing time per alert. Top alerts are sorted by number
+// of transitions, so the most flapping ones go first.
+func Summarize(events []*Event, since time.Time, now time.Time, top int) *Summary {
+	s := &Summary{Since: since, Counts: make(map[string]int)}
+	stats := make(map[string]*AlertStat)
+
+	for _, e := range events {
+		s.Counts[e.Type]++
+
+		st, ok := stats[e.AlertID]
+		if !ok {
+			st = &AlertStat{ID: e.AlertID, Name: e.Name}
+			stats[e.AlertID] = st
+		}
+
+		switch e.Type {
+		case EventNew, EventState, EventResolve:
+			st.Transitions++
+
+			firing := e.Type != EventResolve && e.State == "firing"
+
+			if firing && st.firingSince.IsZero() {
+				st.firingSince = e.Time
+			}
+
+			if !firing && !st.firingSince.IsZero() {
+				st.FiringTime += e.Time.Sub(st.firingSince)
+				st.firingSince = time.Time{}
+			}
+		}
+	}
+
+	for _, st := range stats {
+		if !st.firingSince.IsZero() {
+			st.FiringTime += now.Sub(st.firingSince)
+		}
+
+		s.FiringTime += st.FiringTime
+
+		if st.Transitions > 0 {
+			s.Top = append(s.Top, st)
+		}
+	}
+
+	sort.Slice(s.Top, func(i, j int) bool {
+		if s.Top[i].Transitions == s.Top[j].Transitions {
+			return s.Top[i].FiringTime > s.Top[j].FiringTime
+		}
+
+		return s.Top[i].Transitions > s.Top[j].Transitions
+	})
+
+	if len(s.Top) > top {
+		s.Top = s.Top[:top]
+	}
+
+	return s
+}
+
+// record appends event about alert to history.
+func (a *AlertManager) record(typ string, rec *AlertRec, user string) {
+	al := rec.Alert()
+
+	if al == nil {
+		return
+	}
+
+	e := &Event{
+		Time:    a.now(),
+		Type:    typ,
+		AlertID: al.ID,
+		Name:    al.Name,
+		Labels:  al.Labels,
+		State:   al.State,
+		User:    user,
+	}
+
+	if err := a.hist.Append(e); err != nil {
+		a.logger.Error("can't write alert history", "error", err)
+	}
+}
+
+func (a *AlertManager) History(since time.Time, labels map[string]string) ([]*Event, error) {
+	return a.hist.Query(since, labels)
+}
+
+func (a *AlertManager) pruneHistory() {
+	if a.conf.HistoryRetention <= 0 || a.now().Sub(a.pruned) < time.Hour {
+		return
+	}
+
+	a.pruned = a.now()
+
+	if err := a.hist.Prune(a.now().Add(-a.conf.HistoryRetention)); err != nil {
+		a.logger.Error("can't prune alert history", "error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package alert
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileHistory(t *testing.T) {
+	h := NewFileHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	now := time.Now()
+
+	events, err := h.Query(time.Time{}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	for i, host := range []string{"nas", "pi", "nas"} {
+		require.NoError(t, h.Append(&Event{
+			Time:    now.Add(time.Duration(i-3) * time.Hour),
+			Type:    EventNew,
+			AlertID: host,
+			Name:    "HostDown",
+			Labels:  map[string]string{"host": host},
+		}))
+	}
+
+	events, err = h.Query(time.Time{}, map[string]string{"host": "nas"})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	events, err = h.Query(now.Add(-time.Hour*2-time.Minute), map[string]string{"alertname": "HostDown"})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	require.NoError(t, h.Prune(now.Add(-time.Hour*2-time.Minute)))
+
+	events, err = h.Query(time.Time{}, nil)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "pi", events[0].AlertID)
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Now()
+	at := func(m int) time.Time { return now.Add(time.Duration(m-60) * time.Minute) }
+
+	events := []*Event{
+		{Time: at(0), Type: EventNew, AlertID: "1", Name: "flappy", State: "firing"},
+		{Time: at(5), Type: EventState, AlertID: "1", Name: "flappy", State: "inactive"},
+		{Time: at(10), Type: EventState, AlertID: "1", Name: "flappy", State: "firing"},
+		{Time: at(15), Type: EventResolve, AlertID: "1", Name: "flappy", State: "firing"},
+		{Time: at(30), Type: EventNew, AlertID: "2", Name: "stable", State: "firing"},
+		{Time: at(40), Type: EventMute, AlertID: "2", Name: "stable", State: "firing", User: "user"},
+	}
+
+	s := Summarize(events, at(0), now, 5)
+
+	assert.Equal(t, 2, s.Counts[EventNew])
+	assert.Equal(t, 1, s.Counts[EventMute])
+	require.Len(t, s.Top, 2)
+	assert.Equal(t, "flappy", s.Top[0].Name)
+	assert.Equal(t, 4, s.Top[0].Transitions)
+	assert.Equal(t, time.Minute*10, s.Top[0].FiringTime)
+	assert.Equal(t, time.Minute*30, s.Top[1].FiringTime)
+	assert.Equal(t, time.Minute*40, s.FiringTime)
+}
@@ -0,0 +1,129 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReminderRule sets intervals between reminders. The last interval repeats until Max reminders are sent,
+// zero Max means no limit. No intervals means no reminders.
+type ReminderRule struct {
+	Intervals []time.Duration `koanf:"intervals"`
+	Max       int             `koanf:"max"`
+}
+
+// QuietHours is a daily period like 23:00 - 08:00 of local time when reminders are deferred.
+type QuietHours struct {
+	From string `koanf:"from"`
+	To   string `koanf:"to"`
+
+	from, to int
+}
+
+type ReminderPolicy struct {
+	Default    ReminderRule            `koanf:"default"`
+	Severity   map[string]ReminderRule `koanf:"severity"`
+	QuietHours *QuietHours             `koanf:"quiet_hours"`
+}
+
+// DefaultReminderPolicy reminds about critical alerts every 3 hours.
+func DefaultReminderPolicy() *ReminderPolicy {
+	return &ReminderPolicy{
+		Severity: map[string]ReminderRule{
+			"critical": {Intervals: []time.Duration{time.Hour * 3}},
+		},
+	}
+}
+
+func (p *ReminderPolicy) Validate() error {
+	if p.QuietHours != nil {
+		if err := p.QuietHours.parse(); err != nil {
+			return err
+		}
+	}
+
+	for name, r := range p.Severity {
+		for _, d := range r.Intervals {
+			if d <= 0 {
+				return fmt.Errorf("invalid reminder interval %s for %s", d, name)
+			}
+		}
+	}
+
+	for _, d := range p.Default.Intervals {
+		if d <= 0 {
+			return fmt.Errorf("invalid default reminder interval %s", d)
+		}
+	}
+
+	return nil
+}
+
+func (p *ReminderPolicy) rule(severity string) ReminderRule {
+	if r, ok := p.Severity[severity]; ok {
+		return r
+	}
+
+	return p.Default
+}
+
+// Next returns time of the next reminder after count reminders already sent, last one at last.
+// It returns zero time if there will be no more reminders.
+func (p *ReminderPolicy) Next(severity string, count int, last time.Time) time.Time {
+	r := p.rule(severity)
+
+	if len(r.Intervals) == 0 || (r.Max > 0 && count >= r.Max) {
+		return time.Time{}
+	}
+
+	i := min(count, len(r.Intervals)-1)
+
+	return last.Add(r.Intervals[i])
+}
+
+// Due reports if reminder must be sent now.
+func (p *ReminderPolicy) Due(severity string, count int, last time.Time, now time.Time) bool {
+	next := p.Next(severity, count, last)
+
+	if next.IsZero() || now.Before(next) {
+		return false
+	}
+
+	return p.QuietHours == nil || !p.QuietHours.Contains(now)
+}
+
+func (q *QuietHours) parse() error {
+	var err error
+
+	if q.from, err = parseClock(q.From); err != nil {
+		return err
+	}
+
+	q.to, err = parseClock(q.To)
+
+	return err
+}
+
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q.from == q.to {
+		return false
+	}
+
+	m := t.Hour()*60 + t.Minute()
+
+	if q.from < q.to {
+		return m >= q.from && m < q.to
+	}
+
+	return m >= q.from || m < q.to
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s, must be like 23:00", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
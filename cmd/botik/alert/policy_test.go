@@ -0,0 +1,81 @@
+package alert
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReminderPolicy(t *testing.T) {
+	p := &ReminderPolicy{
+		Default: ReminderRule{Intervals: []time.Duration{time.Hour * 24}, Max: 2},
+		Severity: map[string]ReminderRule{
+			"critical": {Intervals: []time.Duration{time.Minute * 30, time.Hour, time.Hour * 3, time.Hour * 24}},
+			"info":     {},
+		},
+	}
+	require.NoError(t, p.Validate())
+
+	last := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+
+	assert.Equal(t, last.Add(time.Minute*30), p.Next("critical", 0, last))
+	assert.Equal(t, last.Add(time.Hour), p.Next("critical", 1, last))
+	assert.Equal(t, last.Add(time.Hour*3), p.Next("critical", 2, last))
+	assert.Equal(t, last.Add(time.Hour*24), p.Next("critical", 3, last))
+	assert.Equal(t, last.Add(time.Hour*24), p.Next("critical", 10, last))
+
+	assert.Equal(t, last.Add(time.Hour*24), p.Next("warning", 1, last))
+	assert.True(t, p.Next("warning", 2, last).IsZero())
+	assert.True(t, p.Next("info", 0, last).IsZero())
+
+	assert.False(t, p.Due("critical", 0, last, last.Add(time.Minute*29)))
+	assert.True(t, p.Due("critical", 0, last, last.Add(time.Minute*30)))
+}
+
+func TestQuietHours(t *testing.T) {
+	p := &ReminderPolicy{
+		Default:    ReminderRule{Intervals: []time.Duration{time.Hour}},
+		QuietHours: &QuietHours{From: "23:00", To: "08:00"},
+	}
+	require.NoError(t, p.Validate())
+
+	day := func(h, m int) time.Time {
+		return time.Date(2024, 1, 1, h, m, 0, 0, time.Local)
+	}
+
+	assert.True(t, p.QuietHours.Contains(day(23, 0)))
+	assert.True(t, p.QuietHours.Contains(day(3, 0)))
+	assert.False(t, p.QuietHours.Contains(day(8, 0)))
+	assert.False(t, p.QuietHours.Contains(day(12, 0)))
+
+	assert.False(t, p.Due("", 0, day(1, 0), day(7, 59)))
+	assert.True(t, p.Due("", 0, day(1, 0), day(8, 0)))
+
+	assert.Error(t, (&ReminderPolicy{QuietHours: &QuietHours{From: "25:00", To: "1:00"}}).Validate())
+}
+
+func TestReminders(t *testing.T) {
+	var sent []string
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n.Template)
+		return nil
+	})
+	am.SetClock(func() time.Time { return now })
+	require.NoError(t, am.Configure(&Config{Reminders: &ReminderPolicy{
+		Severity: map[string]ReminderRule{"warning": {Intervals: []time.Duration{time.Minute * 30, time.Hour}, Max: 2}},
+	}}))
+
+	ar := NewAlertRec(&Alert{ID: "1", State: "firing", Labels: map[string]string{"severity": "warning"}}, "url", time.Now())
+
+	for _, step := range []time.Duration{0, time.Minute * 29, time.Minute, time.Minute * 59, time.Minute, time.Hour * 10} {
+		now = now.Add(step)
+		am.check(ar)
+	}
+
+	assert.Equal(t, []string{"alert_bad", "reminder", "reminder"}, sent)
+}
@@ -0,0 +1,240 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval   = time.Second * 15
+	defaultPollWorkers    = 4
+	defaultPollRate       = 5
+	defaultPollMaxBackoff = time.Minute * 5
+)
+
+// poller schedules vmalert polls. Its schedule is owned by alertProcessor goroutine,
+// workers only fetch alerts and send results back.
+type poller struct {
+	am       *AlertManager
+	jobs     chan pollJob
+	results  chan pollResult
+	schedule map[string]*pollState
+	limiters map[string]*hostLimiter
+	lmx      sync.Mutex
+}
+
+type pollState struct {
+	next     time.Time
+	failures int
+	busy     bool
+}
+
+type pollJob struct {
+	key string
+	rec *AlertRec
+}
+
+type pollResult struct {
+	key   string
+	rec   *AlertRec
+	alert *Alert
+	err   error
+}
+
+func newPoller(am *AlertManager) *poller {
+	return &poller{
+		am:       am,
+		jobs:     make(chan pollJob, am.conf.PollWorkers),
+		results:  make(chan pollResult, am.conf.PollWorkers),
+		schedule: make(map[string]*pollState),
+		limiters: make(map[string]*hostLimiter),
+	}
+}
+
+func (p *poller) start(ctx context.Context, wg *sync.WaitGroup) {
+	for range p.am.conf.PollWorkers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			p.worker(ctx)
+		}()
+	}
+}
+
+func (p *poller) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-p.jobs:
+			res := pollResult{key: job.key, rec: job.rec}
+			res.alert, res.err = p.fetch(ctx, job.rec.Url())
+
+			select {
+			case p.results <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// fetch gets alert info, waiting for alert host rate limit first.
+func (p *poller) fetch(ctx context.Context, alertUrl string) (*Alert, error) {
+	if err := p.limiter(alertUrl).Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	return p.am.fetchAlertInfo(ctx, alertUrl)
+}
+
+// dispatch sends a poll job for alert if it is due, job is skipped till next tick if all workers are busy.
+func (p *poller) dispatch(key string, rec *AlertRec, now time.Time) {
+	st, ok := p.schedule[key]
+
+	if !ok {
+		st = new(pollState)
+		p.schedule[key] = st
+	}
+
+	if st.busy || now.Before(st.next) {
+		return
+	}
+
+	select {
+	case p.jobs <- pollJob{key: key, rec: rec}:
+		st.busy = true
+	default:
+	}
+}
+
+// done schedules next poll of alert, with exponential backoff if poll has failed.
+func (p *poller) done(key string, err error, now time.Time) *pollState {
+	st, ok := p.schedule[key]
+
+	if !ok {
+		st = new(pollState)
+		p.schedule[key] = st
+	}
+
+	st.busy = false
+
+	if err == nil {
+		st.failures = 0
+		st.next = now.Add(p.am.conf.PollInterval)
+
+		return st
+	}
+
+	st.failures++
+	st.next = now.Add(p.am.backoff(st.failures))
+
+	return st
+}
+
+func (p *poller) forget(key string) {
+	delete(p.schedule, key)
+}
+
+func (p *poller) limiter(alertUrl string) *hostLimiter {
+	host := alertUrl
+
+	if u, err := url.Parse(alertUrl); err == nil {
+		host = u.Host
+	}
+
+	p.lmx.Lock()
+	defer p.lmx.Unlock()
+
+	l, ok := p.limiters[host]
+
+	if !ok {
+		l = newHostLimiter(p.am.conf.PollRate)
+		p.limiters[host] = l
+	}
+
+	return l
+}
+
+// pollDone applies poll result to alert.
+func (a *AlertManager) pollDone(p *poller, res pollResult) {
+	if v, ok := a.alerts.Load(res.key); !ok || v != res.rec {
+		p.forget(res.key)
+		return
+	}
+
+	st := p.done(res.key, res.err, a.now())
+
+	if res.err != nil {
+		a.logger.Warn(fmt.Sprintf("error getting alert %s, retry in %s", res.key, st.next.Sub(a.now()).Round(time.Second)),
+			"error", res.err.Error(), "failures", st.failures)
+		return
+	}
+
+	if res.alert == nil {
+		a.logger.Info(fmt.Sprintf("remove %s alert (404)", res.key))
+		p.forget(res.key)
+
+		if a.alerts.CompareAndDelete(res.key, res.rec) {
+			a.resolve(res.rec)
+		}
+
+		return
+	}
+
+	if a.update(res.rec, res.alert) {
+		a.edit(res.rec)
+	}
+}
+
+func (a *AlertManager) backoff(failures int) time.Duration {
+	d := a.conf.PollInterval
+
+	for i := 1; i < failures && d < a.conf.PollMaxBackoff; i++ {
+		d *= 2
+	}
+
+	return min(d, a.conf.PollMaxBackoff)
+}
+
+// hostLimiter lets requests to a host go no more often than rate per second.
+type hostLimiter struct {
+	interval time.Duration
+	next     time.Time
+	mx       sync.Mutex
+}
+
+func newHostLimiter(rate float64) *hostLimiter {
+	return &hostLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Wait blocks till request can be done or context is done.
+func (l *hostLimiter) Wait(ctx context.Context) error {
+	l.mx.Lock()
+	now := time.Now()
+	at := l.next
+
+	if at.Before(now) {
+		at = now
+	}
+
+	l.next = at.Add(l.interval)
+	l.mx.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	am := NewManager(slog.Default(), nil)
+	require.NoError(t, am.Configure(&Config{PollInterval: time.Second * 10, PollMaxBackoff: time.Minute}))
+
+	assert.Equal(t, time.Second*10, am.backoff(1))
+	assert.Equal(t, time.Second*20, am.backoff(2))
+	assert.Equal(t, time.Second*40, am.backoff(3))
+	assert.Equal(t, time.Minute, am.backoff(4))
+	assert.Equal(t, time.Minute, am.backoff(100))
+}
+
+func TestHostLimiter(t *testing.T) {
+	l := newHostLimiter(20)
+	start := time.Now()
+
+	for range 5 {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*200)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for range 5 {
+		l.Wait(ctx)
+	}
+
+	assert.Error(t, l.Wait(ctx))
+}
+
+func TestPoll(t *testing.T) {
+	var status atomic.Int32
+	var state atomic.Value
+
+	status.Store(http.StatusOK)
+	state.Store("firing")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := int(status.Load()); s != http.StatusOK {
+			w.WriteHeader(s)
+			return
+		}
+
+		json.NewEncoder(w).Encode(&Alert{ID: "1", Name: "test", State: state.Load().(string)})
+	}))
+	defer srv.Close()
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+	require.NoError(t, am.Configure(&Config{PollInterval: time.Second, PollWorkers: 2, PollRate: 100}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := newPoller(am)
+	p.start(ctx, new(sync.WaitGroup))
+
+	url := srv.URL + "/api/v1/alert?id=1"
+	ar := NewAlertRec(&Alert{ID: "1", Name: "test", State: "pending"}, url, time.Now())
+	am.alerts.Store(url, ar)
+
+	poll := func() pollResult {
+		p.schedule[url].next = time.Time{}
+		p.dispatch(url, ar, time.Now())
+
+		select {
+		case res := <-p.results:
+			am.pollDone(p, res)
+			return res
+		case <-time.After(time.Second * 5):
+			t.Fatal("no poll result")
+		}
+
+		return pollResult{}
+	}
+
+	p.dispatch(url, ar, time.Now())
+	assert.True(t, p.schedule[url].busy)
+
+	// alert is already being polled
+	p.dispatch(url, ar, time.Now())
+
+	am.pollDone(p, <-p.results)
+	assert.Equal(t, "firing", ar.Alert().State)
+	assert.False(t, p.schedule[url].busy)
+	assert.True(t, p.schedule[url].next.After(time.Now()))
+
+	status.Store(http.StatusInternalServerError)
+	assert.Error(t, poll().err)
+	assert.Error(t, poll().err)
+	assert.Equal(t, 2, p.schedule[url].failures)
+
+	status.Store(http.StatusOK)
+	state.Store("pending")
+	assert.NoError(t, poll().err)
+	assert.Equal(t, 0, p.schedule[url].failures)
+	assert.Equal(t, "pending", ar.Alert().State)
+
+	status.Store(http.StatusNotFound)
+	poll()
+
+	_, ok := am.alerts.Load(url)
+	assert.False(t, ok)
+	assert.NotContains(t, p.schedule, url)
+}
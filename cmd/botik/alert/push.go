@@ -0,0 +1,137 @@
+package alert
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+const (
+	SourceVmalert      = "vmalert"
+	SourceAlertmanager = "alertmanager"
+)
+
+// WebhookMessage is the Alertmanager webhook payload, version 4.
+type WebhookMessage struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []*PushedAlert    `json:"alerts"`
+}
+
+// PushedAlert is an alert from Alertmanager webhook or from Alertmanager API v2 post, where status and
+// fingerprint are empty.
+type PushedAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// Fingerprint returns hash of alert labels, the same way as Prometheus does.
+func Fingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, k := range names {
+		h.Write([]byte(k))
+		h.Write([]byte{0xff})
+		h.Write([]byte(labels[k]))
+		h.Write([]byte{0xff})
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func (p *PushedAlert) ID() string {
+	if p.Fingerprint != "" {
+		return p.Fingerprint
+	}
+
+	return Fingerprint(p.Labels)
+}
+
+// Resolved reports if alert is over: by status if it is set or by endsAt.
+func (p *PushedAlert) Resolved(now time.Time) bool {
+	if p.Status != "" {
+		return p.Status == "resolved"
+	}
+
+	return !p.EndsAt.IsZero() && !p.EndsAt.After(now)
+}
+
+func (p *PushedAlert) Alert(groupID string) *Alert {
+	al := &Alert{
+		ID:       p.ID(),
+		Name:     p.Labels["alertname"],
+		GroupID:  groupID,
+		State:    "firing",
+		Value:    p.Annotations["value"],
+		Labels:   p.Labels,
+		ActiveAt: p.StartsAt,
+	}
+
+	al.Annotations.Summary = p.Annotations["summary"]
+	al.Annotations.Description = p.Annotations["description"]
+
+	return al
+}
+
+// PushWebhook processes Alertmanager webhook message.
+func (a *AlertManager) PushWebhook(msg *WebhookMessage) {
+	a.Push(SourceAlertmanager, msg.GroupKey, msg.Alerts)
+}
+
+// Push adds, updates or resolves alerts pushed to us. Alerts lifecycle is driven by status and endsAt.
+func (a *AlertManager) Push(source string, groupID string, alerts []*PushedAlert) {
+	now := a.now()
+
+	for _, p := range alerts {
+		key := source + ":" + p.ID()
+
+		if p.Resolved(now) {
+			if v, ok := a.alerts.LoadAndDelete(key); ok {
+				a.logger.Info(fmt.Sprintf("remove %s alert (resolved)", key))
+				a.resolve(v.(*AlertRec))
+			}
+
+			continue
+		}
+
+		v, ok := a.alerts.Load(key)
+
+		al := p.Alert(groupID)
+
+		if ok {
+			rec := v.(*AlertRec)
+			rec.SetEndsAt(p.EndsAt)
+
+			if a.update(rec, al) {
+				a.edit(rec)
+			}
+
+			continue
+		}
+
+		rec := NewPushedAlertRec(al, key, source, p.GeneratorURL, p.EndsAt, a.now())
+		a.alerts.Store(key, rec)
+		a.logger.Info("new pushed alert: " + rec.String())
+		a.record(EventNew, rec, "")
+		a.transition(rec)
+	}
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const webhookFiring = `{
+  "version": "4",
+  "groupKey": "{}:{alertname=\"HostDown\"}",
+  "status": "firing",
+  "receiver": "botik",
+  "groupLabels": {"alertname": "HostDown"},
+  "commonLabels": {"alertname": "HostDown", "severity": "critical"},
+  "commonAnnotations": {},
+  "externalURL": "http://alertmanager:9093",
+  "alerts": [
+    {
+      "status": "firing",
+      "labels": {"alertname": "HostDown", "severity": "critical", "host": "nas"},
+      "annotations": {"summary": "nas is down", "description": "no ping"},
+      "startsAt": "2024-01-01T10:00:00Z",
+      "endsAt": "0001-01-01T00:00:00Z",
+      "generatorURL": "http://prometheus:9090/graph",
+      "fingerprint": "c4f6e5e0e1a2b3c4"
+    }
+  ]
+}`
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint(map[string]string{"alertname": "a", "host": "b"})
+
+	assert.Len(t, fp, 16)
+	assert.Equal(t, fp, Fingerprint(map[string]string{"host": "b", "alertname": "a"}))
+	assert.NotEqual(t, fp, Fingerprint(map[string]string{"alertname": "a", "host": "c"}))
+}
+
+func TestPushWebhook(t *testing.T) {
+	var sent []*Notification
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n)
+		return nil
+	})
+
+	msg := new(WebhookMessage)
+	require.NoError(t, json.Unmarshal([]byte(webhookFiring), msg))
+
+	am.PushWebhook(msg)
+
+	ar := am.FindAlert("c4f6e5e0e1a2b3c4")
+	require.NotNil(t, ar)
+	assert.True(t, ar.IsPushed())
+	assert.Equal(t, SourceAlertmanager, ar.Source())
+	assert.Equal(t, "nas is down", ar.Alert().Title())
+	assert.Equal(t, "HostDown", ar.Alert().Name)
+	assert.False(t, ar.Expired(time.Now()))
+
+	am.check(ar)
+	require.Len(t, sent, 1)
+	assert.Equal(t, "alert_bad", sent[0].Template)
+	assert.Equal(t, SourceAlertmanager, sent[0].Labels()["source"])
+
+	msg.Alerts[0].Status = "resolved"
+	am.PushWebhook(msg)
+
+	assert.Nil(t, am.FindAlert("c4f6e5e0e1a2b3c4"))
+	require.Len(t, sent, 2)
+	assert.Equal(t, "alert_good", sent[1].Template)
+}
+
+func TestPushEndsAt(t *testing.T) {
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+
+	p := &PushedAlert{
+		Labels:   map[string]string{"alertname": "a"},
+		StartsAt: time.Now().Add(-time.Minute),
+		EndsAt:   time.Now().Add(time.Second * 2),
+	}
+
+	am.Push(SourceVmalert, "", []*PushedAlert{p})
+
+	ar := am.FindAlert(p.ID())
+	require.NotNil(t, ar)
+	assert.True(t, ar.Expired(time.Now().Add(time.Second*3)))
+
+	p.EndsAt = time.Now().Add(-time.Second)
+	am.Push(SourceVmalert, "", []*PushedAlert{p})
+	assert.Nil(t, am.FindAlert(p.ID()))
+}
+
+func TestDeliveredLater(t *testing.T) {
+	var sent []*Notification
+
+	// notifier doesn't wait for telegram and reports messages later
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery {
+		sent = append(sent, n)
+		return nil
+	})
+
+	msg := new(WebhookMessage)
+	require.NoError(t, json.Unmarshal([]byte(webhookFiring), msg))
+
+	am.PushWebhook(msg)
+	ar := am.FindAlert("c4f6e5e0e1a2b3c4")
+	am.check(ar)
+
+	require.Len(t, sent, 1)
+	require.NotNil(t, sent[0].Delivered)
+	sent[0].Delivered(Delivery{ChatID: 1, MessageID: 5})
+	assert.Equal(t, []Delivery{{ChatID: 1, MessageID: 5}}, ar.Deliveries())
+
+	msg.Alerts[0].Status = "resolved"
+	am.PushWebhook(msg)
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, "resolved", sent[1].Template)
+	assert.Equal(t, []Delivery{{ChatID: 1, MessageID: 5}}, sent[1].Edit)
+}
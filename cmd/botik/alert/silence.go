@@ -0,0 +1,320 @@
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"botik/internal/util"
+)
+
+// Matcher matches an alert field. Name is a label name or one of the special
+// names "alertname", "group_id" and "id".
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"is_regex,omitempty"`
+
+	re *regexp.Regexp
+}
+
+type Silence struct {
+	ID        string     `json:"id"`
+	Matchers  []*Matcher `json:"matchers"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    time.Time  `json:"ends_at,omitempty"`
+	CreatedBy string     `json:"created_by"`
+	Comment   string     `json:"comment,omitempty"`
+}
+
+func (a *Alert) Field(name string) string {
+	switch name {
+	case "alertname":
+		if v, ok := a.Labels["alertname"]; ok {
+			return v
+		}
+		return a.Name
+	case "group_id":
+		return a.GroupID
+	case "id":
+		return a.ID
+	default:
+		return a.Labels[name]
+	}
+}
+
+func (m *Matcher) compile() error {
+	if m.Name == "" {
+		return fmt.Errorf("empty matcher name")
+	}
+
+	if !m.IsRegex {
+		return nil
+	}
+
+	re, err := regexp.Compile("^(?:" + m.Value + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex for %s: %w", m.Name, err)
+	}
+
+	m.re = re
+
+	return nil
+}
+
+func (m *Matcher) Matches(al *Alert) bool {
+	v := al.Field(m.Name)
+
+	if m.IsRegex {
+		return m.re != nil && m.re.MatchString(v)
+	}
+
+	return v == m.Value
+}
+
+func (m *Matcher) String() string {
+	if m.IsRegex {
+		return fmt.Sprintf("%s=~%q", m.Name, m.Value)
+	}
+
+	return fmt.Sprintf("%s=%q", m.Name, m.Value)
+}
+
+func (s *Silence) validate() error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("silence without matchers")
+	}
+
+	for _, m := range s.Matchers {
+		if err := m.compile(); err != nil {
+			return err
+		}
+	}
+
+	if !s.EndsAt.IsZero() && !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("silence ends before it starts")
+	}
+
+	return nil
+}
+
+// Active reports if silence is in effect at the moment. Zero EndsAt means forever.
+func (s *Silence) Active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && !s.Expired(now)
+}
+
+func (s *Silence) Expired(now time.Time) bool {
+	return !s.EndsAt.IsZero() && !now.Before(s.EndsAt)
+}
+
+func (s *Silence) Matches(al *Alert) bool {
+	if al == nil || len(s.Matchers) == 0 {
+		return false
+	}
+
+	for _, m := range s.Matchers {
+		if !m.Matches(al) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Silence) String() string {
+	ms := make([]string, len(s.Matchers))
+	for i, m := range s.Matchers {
+		ms[i] = m.String()
+	}
+
+	res := fmt.Sprintf("%s {%s} by %s", s.ID, strings.Join(ms, ", "), s.CreatedBy)
+
+	if s.EndsAt.IsZero() {
+		res += " forever"
+	} else {
+		res += " until " + s.EndsAt.Format(util.TIME_FMT)
+	}
+
+	if s.Comment != "" {
+		res += ": " + s.Comment
+	}
+
+	return res
+}
+
+func newSilenceID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func (a *AlertManager) AddSilence(s *Silence) error {
+	if s.StartsAt.IsZero() {
+		s.StartsAt = a.now()
+	}
+
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	if s.ID == "" {
+		s.ID = newSilenceID()
+	}
+
+	a.smx.Lock()
+	a.silences[s.ID] = s
+	a.smx.Unlock()
+
+	a.logger.Info("new silence " + s.String())
+	a.applySilences()
+
+	return nil
+}
+
+// MuteAlert creates a silence for this exact alert. Zero duration means forever.
+func (a *AlertManager) MuteAlert(ar *AlertRec, d time.Duration, author string) (*Silence, error) {
+	al := ar.Alert()
+
+	if al == nil {
+		return nil, fmt.Errorf("empty alert")
+	}
+
+	s := &Silence{
+		Matchers:  []*Matcher{{Name: "id", Value: al.ID}},
+		StartsAt:  a.now(),
+		CreatedBy: author,
+		Comment:   al.Title(),
+	}
+
+	if d > 0 {
+		s.EndsAt = s.StartsAt.Add(d)
+	}
+
+	if err := a.AddSilence(s); err != nil {
+		return nil, err
+	}
+
+	a.record(EventMute, ar, author)
+
+	return s, nil
+}
+
+// mutes tells if silence is made by MuteAlert for the alert with id.
+func (s *Silence) mutes(id string) bool {
+	return len(s.Matchers) == 1 && s.Matchers[0].Name == "id" && !s.Matchers[0].IsRegex && s.Matchers[0].Value == id
+}
+
+// UnmuteAlert expires silences made by MuteAlert for the alert, other silences matching it are kept.
+// It returns number of expired silences.
+func (a *AlertManager) UnmuteAlert(ar *AlertRec, user string) int {
+	var n int
+
+	for _, s := range a.Silences() {
+		if s.mutes(ar.Alert().ID) {
+			if err := a.ExpireSilence(s.ID); err == nil {
+				n++
+			}
+		}
+	}
+
+	if n > 0 {
+		a.record(EventUnmute, ar, user)
+	}
+
+	return n
+}
+
+// ExpireSilence ends the silence now, it will be removed on next check.
+func (a *AlertManager) ExpireSilence(id string) error {
+	a.smx.Lock()
+	s, ok := a.silences[id]
+
+	if !ok {
+		a.smx.Unlock()
+		return fmt.Errorf("silence %s is not found", id)
+	}
+
+	// silences are handed out by Silences, so the expired one is a copy
+	e := *s
+	now := a.now()
+
+	if e.StartsAt.After(now) {
+		e.StartsAt = now
+	}
+
+	e.EndsAt = now
+	a.silences[id] = &e
+	s = &e
+	a.smx.Unlock()
+
+	a.logger.Info("silence expired " + s.String())
+	a.applySilences()
+
+	return nil
+}
+
+// Silences returns not expired silences sorted by start time.
+func (a *AlertManager) Silences() []*Silence {
+	a.smx.RLock()
+	defer a.smx.RUnlock()
+
+	now := a.now()
+	res := make([]*Silence, 0, len(a.silences))
+
+	for _, s := range a.silences {
+		if !s.Expired(now) {
+			res = append(res, s)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].StartsAt.Before(res[j].StartsAt)
+	})
+
+	return res
+}
+
+func (a *AlertManager) IsSilenced(al *Alert) bool {
+	if al == nil {
+		return false
+	}
+
+	a.smx.RLock()
+	defer a.smx.RUnlock()
+
+	now := a.now()
+
+	for _, s := range a.silences {
+		if s.Active(now) && s.Matches(al) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *AlertManager) applySilences() {
+	a.Range(func(ar *AlertRec) bool {
+		ar.SetMuted(a.IsSilenced(ar.Alert()))
+		return true
+	})
+}
+
+func (a *AlertManager) expireSilences() {
+	a.smx.Lock()
+	defer a.smx.Unlock()
+
+	now := a.now()
+
+	for id, s := range a.silences {
+		if s.Expired(now) {
+			a.logger.Info("silence is over " + s.String())
+			delete(a.silences, id)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilenceMatch(t *testing.T) {
+	al := &Alert{
+		ID:      "123",
+		Name:    "DiskFull",
+		GroupID: "grp",
+		Labels:  map[string]string{"host": "nas", "severity": "warning"},
+	}
+
+	tests := []struct {
+		name     string
+		matchers []*Matcher
+		match    bool
+	}{
+		{"label", []*Matcher{{Name: "host", Value: "nas"}}, true},
+		{"label mismatch", []*Matcher{{Name: "host", Value: "pi"}}, false},
+		{"regex", []*Matcher{{Name: "host", Value: "n.*", IsRegex: true}}, true},
+		{"regex anchored", []*Matcher{{Name: "host", Value: "a", IsRegex: true}}, false},
+		{"name", []*Matcher{{Name: "alertname", Value: "DiskFull"}}, true},
+		{"group and id", []*Matcher{{Name: "group_id", Value: "grp"}, {Name: "id", Value: "123"}}, true},
+		{"all must match", []*Matcher{{Name: "group_id", Value: "grp"}, {Name: "severity", Value: "critical"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Silence{Matchers: tt.matchers, StartsAt: time.Now()}
+			require.NoError(t, s.validate())
+			assert.Equal(t, tt.match, s.Matches(al))
+		})
+	}
+}
+
+func TestSilenceExpire(t *testing.T) {
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "alert", State: "firing"}, "url1", time.Now())
+	am.alerts.Store("url1", ar)
+
+	s, err := am.MuteAlert(ar, time.Hour, "user")
+	require.NoError(t, err)
+	assert.True(t, ar.IsMuted())
+	assert.True(t, am.IsSilenced(ar.Alert()))
+	assert.Len(t, am.Silences(), 1)
+
+	ends := s.EndsAt
+
+	require.NoError(t, am.ExpireSilence(s.ID))
+	assert.False(t, ar.IsMuted())
+	assert.Empty(t, am.Silences())
+	// silence handed out before is not changed
+	assert.Equal(t, ends, s.EndsAt)
+
+	am.expireSilences()
+	assert.Error(t, am.ExpireSilence(s.ID))
+
+	assert.Error(t, am.AddSilence(&Silence{Matchers: []*Matcher{{Name: "host", Value: "(", IsRegex: true}}}))
+}
+
+func TestSilenceTime(t *testing.T) {
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	am.now = func() time.Time { return now }
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "alert", State: "firing"}, "url1", now)
+	am.alerts.Store("url1", ar)
+
+	s, err := am.MuteAlert(ar, time.Hour, "user")
+	require.NoError(t, err)
+	assert.Equal(t, now, s.StartsAt)
+	assert.Equal(t, now.Add(time.Hour), s.EndsAt)
+	assert.True(t, am.IsSilenced(ar.Alert()))
+	assert.Equal(t, s, am.activeSilence(ar.Alert()))
+
+	now = now.Add(time.Hour)
+	assert.False(t, am.IsSilenced(ar.Alert()))
+	assert.Empty(t, am.Silences())
+	assert.Nil(t, am.activeSilence(ar.Alert()))
+
+	am.expireSilences()
+	assert.Error(t, am.ExpireSilence(s.ID))
+}
+
+func TestUnmuteKeepsOtherSilences(t *testing.T) {
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "alert", State: "firing", Labels: map[string]string{"host": "nas"}}, "url1", time.Now())
+	am.alerts.Store("url1", ar)
+
+	host := &Silence{Matchers: []*Matcher{{Name: "host", Value: "na.*", IsRegex: true}}, CreatedBy: "other"}
+	require.NoError(t, am.AddSilence(host))
+
+	_, err := am.MuteAlert(ar, 0, "user")
+	require.NoError(t, err)
+	assert.Len(t, am.Silences(), 2)
+
+	assert.Equal(t, 1, am.UnmuteAlert(ar, "user"))
+
+	// the alert is still silenced by the host silence
+	require.Len(t, am.Silences(), 1)
+	assert.Equal(t, host.ID, am.Silences()[0].ID)
+	assert.True(t, ar.IsMuted())
+
+	assert.Equal(t, 0, am.UnmuteAlert(ar, "user"))
+}
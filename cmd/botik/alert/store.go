@@ -0,0 +1,100 @@
+package alert
+
+import (
+	"sync"
+	"time"
+
+	"botik/internal/jsonfile"
+)
+
+// Store keeps AlertManager state between restarts.
+type Store interface {
+	Load() (*State, error)
+	Save(state *State) error
+}
+
+type State struct {
+	Alerts   []*AlertRecState `json:"alerts"`
+	Silences []*Silence       `json:"silences,omitempty"`
+}
+
+type AlertRecState struct {
+	Key        string     `json:"key,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	Url        string     `json:"url"`
+	Pushed     bool       `json:"pushed,omitempty"`
+	EndsAt     time.Time  `json:"ends_at,omitempty"`
+	Alert      *Alert     `json:"alert,omitempty"`
+	Created    time.Time  `json:"created"`
+	LastNotify time.Time  `json:"last_notify"`
+	Reminders  int        `json:"reminders,omitempty"`
+	Muted      bool       `json:"muted,omitempty"`
+	Flapping   bool       `json:"flapping,omitempty"`
+	AckedBy    string     `json:"acked_by,omitempty"`
+	AckedAt    time.Time  `json:"acked_at,omitempty"`
+	Pages      []Page     `json:"pages,omitempty"`
+	New        bool       `json:"new,omitempty"`
+	Deliveries []Delivery `json:"deliveries,omitempty"`
+
+	// FlapTransitions are recent state changes counted by flap detection
+	FlapTransitions []time.Time `json:"flap_transitions,omitempty"`
+}
+
+type MemoryStore struct {
+	state *State
+	mx    sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Load() (*State, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if m.state == nil {
+		return new(State), nil
+	}
+
+	return m.state, nil
+}
+
+func (m *MemoryStore) Save(state *State) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.state = state
+
+	return nil
+}
+
+// FileStore keeps state as a json file, rewriting it atomically on every save.
+type FileStore struct {
+	path string
+	mx   sync.Mutex
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (f *FileStore) Load() (*State, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	state := new(State)
+
+	if _, err := jsonfile.Load(f.path, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (f *FileStore) Save(state *State) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	return jsonfile.Save(f.path, state)
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	st := NewFileStore(filepath.Join(t.TempDir(), "alerts.json"))
+
+	state, err := st.Load()
+	require.NoError(t, err)
+	assert.Empty(t, state.Alerts)
+
+	now := time.Now().Truncate(time.Second)
+	rec := &AlertRecState{
+		Url:             "http://vmalert/api/v1/alert?group_id=1&alert_id=2",
+		Alert:           &Alert{ID: "2", Name: "alert", State: "firing"},
+		Created:         now,
+		LastNotify:      now,
+		Muted:           true,
+		Flapping:        true,
+		FlapTransitions: []time.Time{now.Add(-time.Minute), now},
+	}
+
+	require.NoError(t, st.Save(&State{Alerts: []*AlertRecState{rec}}))
+
+	state, err = st.Load()
+	require.NoError(t, err)
+	require.Len(t, state.Alerts, 1)
+	assert.Equal(t, rec.Url, state.Alerts[0].Url)
+	assert.Equal(t, "2", state.Alerts[0].Alert.ID)
+	assert.True(t, state.Alerts[0].LastNotify.Equal(now))
+	assert.True(t, state.Alerts[0].Muted)
+	assert.False(t, state.Alerts[0].New)
+	assert.True(t, state.Alerts[0].Flapping)
+	require.Len(t, state.Alerts[0].FlapTransitions, 2)
+	assert.True(t, state.Alerts[0].FlapTransitions[1].Equal(now))
+}
+
+func TestManagerRestore(t *testing.T) {
+	st := NewMemoryStore()
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+	am.SetStore(st)
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "alert", State: "firing"}, "url1", time.Now())
+	ar.Notified(time.Now()).SetMuted(true)
+	am.alerts.Store("url1", ar)
+	am.save()
+
+	am2 := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+	am2.SetStore(st)
+	am2.restore()
+
+	v, ok := am2.alerts.Load("url1")
+	require.True(t, ok)
+
+	restored := v.(*AlertRec)
+	assert.True(t, restored.IsMuted())
+	assert.False(t, restored.IsNew())
+	assert.Equal(t, ar.LastNotify(), restored.LastNotify())
+	assert.Equal(t, "1", restored.Alert().ID)
+}
+
+func TestManagerStop(t *testing.T) {
+	st := NewMemoryStore()
+
+	am := NewManager(slog.Default(), func(n *Notification) []Delivery { return nil })
+	am.SetStore(st)
+	am.Start(context.Background())
+
+	am.Push(SourceAlertmanager, "", []*PushedAlert{{Labels: map[string]string{"alertname": "test"}}})
+	am.Stop()
+
+	state, err := st.Load()
+	require.NoError(t, err)
+	assert.Len(t, state.Alerts, 1)
+}
+
+func TestManagerRestoreFlapping(t *testing.T) {
+	st := NewMemoryStore()
+	conf := &Config{FlapThreshold: 2, FlapWindow: time.Hour}
+
+	var sent []*Notification
+	notifier := func(n *Notification) []Delivery {
+		sent = append(sent, n)
+		return nil
+	}
+
+	am := NewManager(slog.Default(), notifier)
+	require.NoError(t, am.Configure(conf))
+	am.SetStore(st)
+
+	ar := NewAlertRec(&Alert{ID: "1", Name: "alert", State: "firing"}, "url1", time.Now())
+	am.alerts.Store("url1", ar)
+	am.transition(ar)
+	am.transition(ar)
+	am.checkFlaps()
+	require.True(t, ar.IsFlapping())
+	am.save()
+
+	am2 := NewManager(slog.Default(), notifier)
+	require.NoError(t, am2.Configure(conf))
+	am2.SetStore(st)
+	am2.restore()
+	am2.checkFlaps()
+
+	v, ok := am2.alerts.Load("url1")
+	require.True(t, ok)
+	assert.True(t, v.(*AlertRec).IsFlapping())
+	assert.True(t, am2.IsFlapping("1"))
+	assert.Equal(t, 2, am2.flapCount("1"))
+
+	// only "flapping" of the first manager, no "flapping_stopped" after restart
+	require.Len(t, sent, 1)
+	assert.Equal(t, "flapping", sent[0].Template)
+}
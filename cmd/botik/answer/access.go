@@ -0,0 +1,92 @@
+package answer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Access maps users to roles and roles to permissions. Permission is "*", answerer name
+// like "light" to allow all its commands, or "answerer:CMD" like "light:STATUS" to allow one command.
+type Access struct {
+	roles map[string][]string
+	users map[string][]string
+}
+
+func NewAccess(roles map[string][]string, users map[string][]string) *Access {
+	a := &Access{
+		roles: make(map[string][]string, len(roles)),
+		users: make(map[string][]string, len(users)),
+	}
+
+	for role, perms := range roles {
+		a.roles[strings.ToLower(role)] = perms
+	}
+
+	for user, roles := range users {
+		a.users[strings.ToLower(user)] = roles
+	}
+
+	return a
+}
+
+// Allowed checks if user has permission to run command cmd of answerer.
+func (a *Access) Allowed(user, answerer, cmd string) bool {
+	if a == nil {
+		return true
+	}
+
+	for _, role := range a.users[strings.ToLower(user)] {
+		for _, perm := range a.roles[strings.ToLower(role)] {
+			if permits(perm, answerer, cmd) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Roles returns user roles.
+func (a *Access) Roles(user string) []string {
+	if a == nil {
+		return nil
+	}
+
+	return a.users[strings.ToLower(user)]
+}
+
+// RolesFor returns sorted roles which have permission to run command cmd of answerer.
+func (a *Access) RolesFor(answerer, cmd string) []string {
+	if a == nil {
+		return nil
+	}
+
+	var res []string
+
+	for role, perms := range a.roles {
+		for _, perm := range perms {
+			if permits(perm, answerer, cmd) {
+				res = append(res, role)
+				break
+			}
+		}
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+func permits(perm, answerer, cmd string) bool {
+	if perm == "*" {
+		return true
+	}
+
+	name, c, ok := strings.Cut(perm, ":")
+
+	if !strings.EqualFold(name, answerer) {
+		return false
+	}
+
+	return !ok || c == "*" || strings.EqualFold(c, cmd)
+}
@@ -0,0 +1,97 @@
+package answer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAnswerer struct {
+	name string
+}
+
+func (t *testAnswerer) Check(user string, msg string, repl string) *Q {
+	q := &Q{Msg: msg, User: user}
+
+	if w := q.Words(); w[0] == t.name {
+		q.Matched = true
+		q.Cmd = STATUS
+
+		if len(w) > 1 {
+			q.Cmd = w[1]
+		}
+	}
+
+	return q
+}
+
+func (t *testAnswerer) Process(q *Q) *Answer {
+	return TextAnswer("ok " + q.Cmd)
+}
+
+func TestAccess(t *testing.T) {
+	a := NewAccess(
+		map[string][]string{
+			"admin":  {"*"},
+			"family": {"light", "alerts:mute", "bp"},
+			"guest":  {"light:STATUS", "alerts:*"},
+		},
+		map[string][]string{
+			"Admin":  {"admin"},
+			"mom":    {"family"},
+			"guest":  {"guest"},
+			"nobody": {"unknown"},
+		},
+	)
+
+	tests := []struct {
+		user     string
+		answerer string
+		cmd      string
+		allowed  bool
+	}{
+		{"admin", "light", ON, true},
+		{"admin", "bp", BP_OTHER, true},
+		{"mom", "light", NIGHT, true},
+		{"mom", "alerts", "mute", true},
+		{"mom", "alerts", "ack", false},
+		{"mom", "bp", BP_OTHER, true},
+		{"guest", "light", "status", true},
+		{"guest", "light", ON, false},
+		{"guest", "alerts", "ack", true},
+		{"guest", "bp", BP, false},
+		{"nobody", "light", STATUS, false},
+		{"stranger", "light", STATUS, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, a.Allowed(tt.user, tt.answerer, tt.cmd), "%s %s:%s", tt.user, tt.answerer, tt.cmd)
+	}
+
+	assert.Equal(t, []string{"admin", "family", "guest"}, a.RolesFor("light", STATUS))
+	assert.Equal(t, []string{"admin", "family"}, a.RolesFor("bp", BP_OTHER))
+
+	var noAccess *Access
+	assert.True(t, noAccess.Allowed("anyone", "light", ON))
+	assert.Empty(t, noAccess.RolesFor("light", ON))
+}
+
+func TestCheckAnswerAccess(t *testing.T) {
+	am := New()
+	assert.NoError(t, am.RegisterAnswer("light", &testAnswerer{name: "light"}, PriorityNormal))
+
+	assert.Equal(t, "ok on", am.CheckAnswer(&Request{User: "guest", Msg: "light ON"}).Msg)
+
+	am.SetAccess(NewAccess(map[string][]string{"guest": {"light:STATUS"}}, map[string][]string{"guest": {"guest"}}))
+
+	assert.Equal(t, "ok STATUS", am.CheckAnswer(&Request{User: "guest", Msg: "light"}).Msg)
+	assert.Contains(t, am.CheckAnswer(&Request{User: "guest", Msg: "light ON"}).Msg, "нет прав")
+}
+
+func TestCheckAnswerChatAnswerers(t *testing.T) {
+	am := New()
+	assert.NoError(t, am.RegisterAnswer("light", &testAnswerer{name: "light"}, PriorityNormal))
+
+	assert.Equal(t, "ok on", am.CheckAnswer(&Request{User: "user", Msg: "light ON", Answerers: []string{"light"}}).Msg)
+	assert.Contains(t, am.CheckAnswer(&Request{User: "user", Msg: "light ON", Answerers: []string{"alerts"}}).Msg, "недоступно")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 )
 
 type Alerts struct {
@@ -20,62 +21,164 @@ func NewAlerts(logger *slog.Logger, am *alert.AlertManager) *Alerts {
 	}
 }
 
+var alertsGrammar = Grammar{
+	{Cmd: "mute", Phrases: []string{"mute", "выкл"}, Slots: []Slot{{Name: "duration", Kind: SlotDuration}}},
+	{Cmd: "ack", Phrases: []string{"ack", "принял"}, Slots: []Slot{{Name: "id", Kind: SlotWord}}},
+	{Cmd: "unmute", Phrases: []string{"unmute"}, Slots: []Slot{{Name: "id", Kind: SlotWord}}},
+	{Cmd: "history", Phrases: []string{"history", "история"}, Slots: []Slot{{Name: "duration", Kind: SlotDuration}}},
+	{Cmd: "silences", Phrases: []string{"silences", "тишина"}},
+	{Cmd: "alerts", Phrases: []string{"alerts", "алерты"}},
+}
+
 func (cam *Alerts) Check(user string, msg string, repl string) (q *Q) {
-	q = &Q{Msg: msg, User: strings.ToLower(user)}
+	q = alertsGrammar.Check(strings.ToLower(user), msg, repl)
 
-	words := q.Words()
+	switch q.Cmd {
+	case "mute":
+		q.Payload = q.Slots["duration"]
 
-	if util.IsInArray(words[0], "mute", "выкл") {
-		q.Matched = true
-		q.Prefix = words[0]
-		q.Cmd = "mute"
-		q.Repl = repl
-		return
-	}
+		// not a duration, Process tells it is invalid
+		if q.Payload == "" && len(q.Rest) > 0 {
+			q.Payload = q.Rest[0]
+		}
+	case "ack", "unmute":
+		q.Payload = q.Slots["id"]
+	case "history":
+		q.Payload = q.Slots["duration"]
 
-	if util.IsInArray(words[0], "alerts", "алерты") {
-		q.Matched = true
-		q.Prefix = words[0]
-		q.Cmd = "alerts"
-		q.Repl = repl
-		return
+		if q.Payload == "" {
+			q.Payload = "24h"
+		}
 	}
 
-	return
+	return q
+}
+
+func (cam *Alerts) Commands() []Command {
+	return []Command{
+		{Name: "alerts", Aliases: []string{"алерты"}, Cmd: "alerts", Description: "активные алерты", DescriptionEn: "active alerts"},
+		{Name: "mute", Aliases: []string{"выкл"}, Cmd: "mute", Args: "[длительность]",
+			Description: "заглушить алерт, ответом на сообщение с алертом", DescriptionEn: "mute alert, as a reply to alert message"},
+		{Name: "unmute", Cmd: "unmute", Args: "[silence id]",
+			Description: "снять silence или mute алерта, ответом на сообщение", DescriptionEn: "expire silence or mute of replied alert"},
+		{Name: "ack", Aliases: []string{"принял"}, Cmd: "ack", Args: "[id]", Description: "подтвердить алерт", DescriptionEn: "acknowledge alert"},
+		{Name: "silences", Aliases: []string{"тишина"}, Cmd: "silences", Description: "активные silence", DescriptionEn: "active silences"},
+		{Name: "history", Aliases: []string{"история"}, Cmd: "history", Args: "[период]",
+			Description: "история алертов, по умолчанию за 24h", DescriptionEn: "alert history, 24h by default"},
+	}
 }
 
 func (cam *Alerts) Process(q *Q) *Answer {
 	switch q.Cmd {
 	case "mute":
-		var id string
-		for _, s := range strings.Split(q.Repl, "\n") {
-			if strings.HasPrefix(s, "id:") {
-				id = s[3:]
-				break
+		id := alertId(q.Repl)
+
+		if id == "" {
+			return TextAnswer("ответьте на сообщение с алертом: mute, mute 2h, mute 1d")
+		}
+
+		var d time.Duration
+
+		if q.Payload != "" {
+			var err error
+			if d, err = util.ParseDuration(q.Payload); err != nil || d < 0 {
+				return TextAnswer("неверная длительность " + q.Payload)
 			}
 		}
 
+		cam.logger.Info("mute id " + id)
+
+		ar := cam.am.FindAlert(id)
+
+		if ar == nil {
+			return TextAnswer("alert with id is not found")
+		}
+
+		s, err := cam.am.MuteAlert(ar, d, q.User)
+
+		if err != nil {
+			return ErrorAnswer(err)
+		}
+
+		if s.EndsAt.IsZero() {
+			return TextAnswer(fmt.Sprintf("alert %s is muted", ar.Alert().Name))
+		}
+
+		return TextAnswer(fmt.Sprintf("alert %s is muted until %s", ar.Alert().Name, s.EndsAt.Format(util.TIME_FMT)))
+
+	case "ack":
+		id := q.Payload
+
 		if id == "" {
-			return nil
+			id = alertId(q.Repl)
 		}
 
-		cam.logger.Info("mute id " + id)
+		if id == "" {
+			return TextAnswer("ответьте ack на сообщение с алертом или укажите id: ack <id>")
+		}
 
-		var ans string
-		cam.am.Range(func(ar *alert.AlertRec) bool {
-			if ar.Alert().ID == id {
-				ar.Mute()
-				ans = fmt.Sprintf("alert %s is muted", ar.Alert().Name)
-				return false
+		ar := cam.am.FindAlert(id)
+
+		if ar == nil {
+			return TextAnswer("alert with id is not found")
+		}
+
+		if err := cam.am.Ack(ar, q.User); err != nil {
+			return TextAnswer(err.Error())
+		}
+
+		return TextAnswer(fmt.Sprintf("alert %s is acknowledged", ar.Alert().Name))
+
+	case "unmute":
+		if q.Payload != "" {
+			if err := cam.am.ExpireSilence(q.Payload); err != nil {
+				return TextAnswer(err.Error())
 			}
 
-			return true
-		})
+			return TextAnswer("silence " + q.Payload + " expired")
+		}
+
+		id := alertId(q.Repl)
+
+		if id == "" {
+			return TextAnswer("использование: unmute <silence id> или ответ на сообщение с алертом")
+		}
+
+		ar := cam.am.FindAlert(id)
+
+		if ar == nil {
+			return TextAnswer("alert with id is not found")
+		}
+
+		n := cam.am.UnmuteAlert(ar, q.User)
+
+		return TextAnswer(fmt.Sprintf("expired %d silences for %s", n, ar.Alert().Name))
+
+	case "history":
+		d, err := util.ParseDuration(q.Payload)
+		if err != nil {
+			return TextAnswer("неверная длительность " + q.Payload)
+		}
+
+		since := time.Now().Add(-d)
+		events, err := cam.am.History(since, nil)
+
+		if err != nil {
+			return ErrorAnswer(err)
+		}
+
+		return TextAnswer(formatSummary(alert.Summarize(events, since, time.Now(), 5), q.Payload))
+
+	case "silences":
+		var ans string
+		for _, s := range cam.am.Silences() {
+			ans += "- " + s.String() + "\n"
+		}
 
 		if ans != "" {
 			return TextAnswer(ans)
 		} else {
-			return TextAnswer("alert with id is not found")
+			return TextAnswer("нет активных silence")
 		}
 
 	case "alerts":
@@ -96,3 +199,42 @@ func (cam *Alerts) Process(q *Q) *Answer {
 		return TextAnswer("invalid command " + q.Cmd)
 	}
 }
+
+// alertId gets alert id from the text of alert notification.
+func alertId(text string) string {
+	for _, s := range strings.Split(text, "\n") {
+		if strings.HasPrefix(s, "id:") {
+			return strings.TrimSpace(s[3:])
+		}
+	}
+
+	return ""
+}
+
+func formatSummary(s *alert.Summary, period string) string {
+	if len(s.Counts) == 0 {
+		return "за " + period + " алертов не было"
+	}
+
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "история алертов за %s\n\n", period)
+
+	for _, typ := range []string{alert.EventNew, alert.EventResolve, alert.EventState, alert.EventReminder,
+		alert.EventMute, alert.EventUnmute, alert.EventAck, alert.EventEscalation} {
+		if n := s.Counts[typ]; n > 0 {
+			fmt.Fprintf(sb, "%s: %d\n", typ, n)
+		}
+	}
+
+	fmt.Fprintf(sb, "\nвсего в firing: %s\n", s.FiringTime.Round(time.Minute))
+
+	if len(s.Top) > 0 {
+		sb.WriteString("\nчаще всего:\n")
+
+		for _, st := range s.Top {
+			fmt.Fprintf(sb, "- %s: %d переходов, firing %s\n", st.Name, st.Transitions, st.FiringTime.Round(time.Minute))
+		}
+	}
+
+	return sb.String()
+}
@@ -2,32 +2,142 @@ package answer
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
+	"unicode/utf8"
+
+	"botik/cmd/botik/audit"
+)
+
+// answerer priorities, on equal match score answerer with higher priority wins
+const (
+	PriorityLow    = -10
+	PriorityNormal = 0
+	PriorityHigh   = 10
 )
 
 type AnswerManager struct {
 	answerers map[string]Answerer
-	mx        sync.RWMutex
+	// ordered is answerers by priority, then by registration order
+	ordered []*registered
+	access  *Access
+	auditor *audit.Auditor
+	mx      sync.RWMutex
+	// sessions are conversations waiting for the answer of user in chat
+	sessions map[sessionKey]*Session
+	smx      sync.Mutex
+	now      func() time.Time
 }
 
 func New() *AnswerManager {
 	return &AnswerManager{
 		answerers: make(map[string]Answerer),
 		mx:        sync.RWMutex{},
+		sessions:  make(map[sessionKey]*Session),
+		now:       time.Now,
 	}
 }
 
+type registered struct {
+	name     string
+	ans      Answerer
+	priority int
+}
+
 type Answerer interface {
 	Check(user string, msg string, repl string) *Q
 	Process(q *Q) *Answer
 }
 
+// Restricted answerer needs permission even if access control is off.
+type Restricted interface {
+	Restricted() bool
+}
+
+// parse modes of answer text
+const (
+	ModeMarkdown = "MarkdownV2"
+	ModeHTML     = "HTML"
+)
+
 type Answer struct {
 	Msg   string
 	Photo string
+	// Photos are sent as an album, Msg is its caption
+	Photos []string
+	// Document is a file, Msg is its caption
+	Document *File
+	// ParseMode of Msg, plain text if empty
+	ParseMode string
+	Keyboard  *Keyboard
+	// Silent answer comes without notification sound
+	Silent bool
+	// ReplyTo is id of the message to reply to, the message of the user in groups if 0
+	ReplyTo int
+	// Edit is id of the message to replace with Msg and inline keyboard instead of sending a new one
+	Edit int
+	// Err is set if command has failed
+	Err error
+	// Ask is a question, the next message of the user is routed to the answerer
+	Ask *Question
+}
+
+// File is a file on disk or in memory if Data is set.
+type File struct {
+	Name string
+	Path string
+	Data []byte
+}
+
+// Button is a keyboard button, inline button has callback Data or URL. Data is passed to Click of
+// Clickable answerer, telegram limits it to 64 bytes with answerer and command names.
+type Button struct {
+	Text string
+	Data string
+	URL  string
+}
+
+// Keyboard is inline keyboard under the message or reply keyboard instead of the user keyboard.
+type Keyboard struct {
+	Inline bool
+	Rows   [][]Button
+	// OneTime reply keyboard is hidden after use
+	OneTime bool
+	// Remove hides reply keyboard
+	Remove bool
+}
+
+// InlineKeyboard makes inline keyboard with buttons rows.
+func InlineKeyboard(rows ...[]Button) *Keyboard {
+	return &Keyboard{Inline: true, Rows: rows}
+}
+
+// ReplyKeyboard makes one time reply keyboard with a button per row for each choice.
+func ReplyKeyboard(choices ...string) *Keyboard {
+	k := &Keyboard{OneTime: true}
+
+	for _, c := range choices {
+		k.Rows = append(k.Rows, []Button{{Text: c}})
+	}
+
+	return k
+}
+
+// Request is a message to the bot.
+type Request struct {
+	User string
+	Chat int64
+	Msg  string
+	// Repl is a text of the message this one replies to
+	Repl string
+	// Answerers allowed in the chat, all if empty
+	Answerers []string
+	// Lang is user language code from telegram, like "en"
+	Lang string
 }
 
 type Q struct {
@@ -38,6 +148,12 @@ type Q struct {
 	Payload string
 	Matched bool
 	User    string
+	// Score is how specific the match is, see Specificity
+	Score int
+	// Slots are command arguments found by grammar
+	Slots map[string]string
+	// Rest is words of the message not taken by grammar
+	Rest []string
 }
 
 func TextAnswer(msg string) *Answer {
@@ -48,6 +164,42 @@ func PhotoAnswer(file string) *Answer {
 	return &Answer{Photo: file}
 }
 
+func MarkdownAnswer(msg string) *Answer {
+	return &Answer{Msg: msg, ParseMode: ModeMarkdown}
+}
+
+func AlbumAnswer(caption string, files ...string) *Answer {
+	return &Answer{Msg: caption, Photos: files}
+}
+
+func DocumentAnswer(caption string, f *File) *Answer {
+	return &Answer{Msg: caption, Document: f}
+}
+
+func ErrorAnswer(err error) *Answer {
+	return &Answer{Msg: "ошибка: " + err.Error(), Err: err}
+}
+
+// Specificity tells how well the message matches, more is better. It is q.Score if answerer has set it,
+// otherwise it grows with the length of matched prefix, and whole word match is better than part of a word
+// of the same length, so for "выключи свет" prefix "выключи" wins over "выкл".
+func (q *Q) Specificity() int {
+	if q.Score != 0 {
+		return q.Score
+	}
+
+	prefix := strings.ToLower(q.Prefix)
+	score := 2*utf8.RuneCountInString(prefix) + 1
+
+	if rest, ok := strings.CutPrefix(strings.ToLower(q.Msg), prefix); ok && prefix != "" {
+		if r, _ := utf8.DecodeRuneInString(rest); rest == "" || unicode.IsSpace(r) || unicode.IsPunct(r) {
+			score++
+		}
+	}
+
+	return score
+}
+
 func (q *Q) Words() []string {
 	res := strings.FieldsFunc(strings.ToLower(q.Msg), func(r rune) bool {
 		//return unicode.IsSpace(r) || unicode.IsPunct(r)
@@ -60,7 +212,8 @@ func (q *Q) Words() []string {
 	return res
 }
 
-func (am *AnswerManager) RegisterAnswer(name string, ans Answerer) error {
+// RegisterAnswer adds answerer with priority, it is used when two answerers match the message equally well.
+func (am *AnswerManager) RegisterAnswer(name string, ans Answerer, priority int) error {
 	am.mx.Lock()
 	defer am.mx.Unlock()
 
@@ -70,22 +223,163 @@ func (am *AnswerManager) RegisterAnswer(name string, ans Answerer) error {
 	}
 
 	am.answerers[name] = ans
+	am.ordered = append(am.ordered, &registered{name: name, ans: ans, priority: priority})
+
+	sort.SliceStable(am.ordered, func(i, j int) bool {
+		return am.ordered[i].priority > am.ordered[j].priority
+	})
+
 	return nil
 }
 
-func (am *AnswerManager) CheckAnswer(user string, msg string, repl string) *Answer {
+// SetAccess turns on access control, every known user can do everything if it is not set.
+func (am *AnswerManager) SetAccess(a *Access) {
+	am.mx.Lock()
+	defer am.mx.Unlock()
+
+	am.access = a
+}
+
+// SetAuditor sets audit log for commands.
+func (am *AnswerManager) SetAuditor(a *audit.Auditor) {
+	am.mx.Lock()
+	defer am.mx.Unlock()
+
+	am.auditor = a
+}
+
+// Allowed checks if user has permission to run command cmd of answerer.
+func (am *AnswerManager) Allowed(user, answerer, cmd string) bool {
 	am.mx.RLock()
 	defer am.mx.RUnlock()
 
-	msg1 := strings.TrimLeft(msg, "/")
+	return am.allowed(user, answerer, cmd)
+}
+
+func (am *AnswerManager) allowed(user, answerer, cmd string) bool {
+	if am.access == nil {
+		r, ok := am.answerers[answerer].(Restricted)
+		return !ok || !r.Restricted()
+	}
+
+	return am.access.Allowed(user, answerer, cmd)
+}
+
+// candidate is an answerer matched the message.
+type candidate struct {
+	name     string
+	ans      Answerer
+	q        *Q
+	priority int
+}
+
+// match finds the best answerer for the message. The most specific match wins, then the one with higher priority,
+// if there are several equally good matches all of them are returned as ambiguous.
+func (am *AnswerManager) match(req *Request) (best *candidate, ambiguous []*candidate) {
+	msg := strings.TrimLeft(req.Msg, "/")
+
+	if strings.TrimSpace(msg) == "" {
+		return nil, nil
+	}
+
+	var matches []*candidate
+
+	for _, r := range am.ordered {
+		if q := r.ans.Check(req.User, msg, req.Repl); q.Matched {
+			matches = append(matches, &candidate{name: r.name, ans: r.ans, q: q, priority: r.priority})
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	// ordered is sorted by priority and registration, stable sort keeps it for equal scores
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].q.Specificity() > matches[j].q.Specificity()
+	})
+
+	best = matches[0]
+
+	for _, m := range matches[1:] {
+		if m.q.Specificity() == best.q.Specificity() && m.priority == best.priority {
+			ambiguous = append(ambiguous, m)
+		}
+	}
+
+	if len(ambiguous) > 0 {
+		return nil, append([]*candidate{best}, ambiguous...)
+	}
+
+	return best, nil
+}
 
-	for _, ans := range am.answerers {
-		if q := ans.Check(user, msg1, repl); q.Matched {
-			return ans.Process(q)
+func (am *AnswerManager) CheckAnswer(req *Request) *Answer {
+	am.mx.RLock()
+	defer am.mx.RUnlock()
+
+	if a := am.converse(req); a != nil {
+		return a
+	}
+
+	if topic, ok := isHelp(strings.TrimLeft(req.Msg, "/")); ok {
+		return am.help(req, topic)
+	}
+
+	e := &audit.Entry{Source: audit.SourceTelegram, User: req.User, Chat: req.Chat, Msg: req.Msg}
+
+	best, ambiguous := am.match(req)
+
+	if len(ambiguous) > 0 {
+		var names []string
+
+		for _, m := range ambiguous {
+			names = append(names, fmt.Sprintf("%s (%s)", m.name, strings.ToLower(m.q.Cmd)))
 		}
+
+		e.Outcome = audit.OutcomeAmbiguous
+		e.Error = strings.Join(names, ", ")
+		am.auditor.Record(e)
+
+		return TextAnswer(fmt.Sprintf("не понял, что именно: %s? уточните команду", strings.Join(names, " или ")))
+	}
+
+	if best == nil {
+		e.Outcome = audit.OutcomeUnknown
+		am.auditor.Record(e)
+
+		return TextAnswer(fmt.Sprintf("я не знаю, что такое %s", req.Msg))
 	}
 
-	return TextAnswer(fmt.Sprintf("я не знаю, что такое %s", msg))
+	name, q := best.name, best.q
+	e.Answerer, e.Cmd, e.Payload = name, q.Cmd, q.Payload
+
+	if len(req.Answerers) > 0 && !slices.Contains(req.Answerers, name) {
+		e.Outcome = audit.OutcomeDenied
+		am.auditor.Record(e)
+
+		return TextAnswer(fmt.Sprintf("в этом чате %s недоступно", name))
+	}
+
+	if !am.allowed(req.User, name, q.Cmd) {
+		e.Outcome = audit.OutcomeDenied
+		am.auditor.Record(e)
+
+		return TextAnswer(fmt.Sprintf("%s, у тебя нет прав на это (%s %s)", req.User, name, strings.ToLower(q.Cmd)))
+	}
+
+	a := best.ans.Process(q)
+
+	if a != nil && a.Err != nil {
+		e.Outcome = audit.OutcomeError
+		e.Error = a.Err.Error()
+	}
+
+	am.auditor.Record(e)
+	am.startSession(req, name, q, a)
+	bindButtons(name, q.Cmd, a)
+
+	return a
 }
 
 func IndexOf(words []string, element ...string) int {
@@ -0,0 +1,116 @@
+package answer
+
+import (
+	"botik/cmd/botik/audit"
+	"botik/internal/util"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const auditLines = 30
+
+// Audit shows audit log, only users with "audit" permission can see it.
+type Audit struct {
+	auditor *audit.Auditor
+}
+
+func NewAudit(a *audit.Auditor) *Audit {
+	return &Audit{auditor: a}
+}
+
+func (a *Audit) Restricted() bool {
+	return true
+}
+
+var auditGrammar = Grammar{
+	{Cmd: "audit", Phrases: []string{"audit", "аудит"}, Slots: []Slot{{Name: "duration", Kind: SlotDuration}}},
+}
+
+func (a *Audit) Check(user string, msg string, repl string) (q *Q) {
+	q = auditGrammar.Check(strings.ToLower(user), msg, repl)
+	q.Payload = q.Slots["duration"]
+
+	if q.Payload == "" {
+		q.Payload = "24h"
+	}
+
+	return q
+}
+
+func (a *Audit) Commands() []Command {
+	return []Command{
+		{Name: "audit", Aliases: []string{"аудит"}, Cmd: "audit", Args: "[период] [пользователь] [csv]",
+			Description: "журнал команд, по умолчанию за 24h", DescriptionEn: "command log, 24h by default"},
+	}
+}
+
+// Process shows last entries for period in q.Payload, other word of message is an optional user name or csv.
+func (a *Audit) Process(q *Q) *Answer {
+	d, err := util.ParseDuration(q.Payload)
+	if err != nil {
+		return TextAnswer("неверная длительность " + q.Payload)
+	}
+
+	var user string
+	var export bool
+
+	for _, w := range q.Rest {
+		if w == "csv" {
+			export = true
+		} else {
+			user = w
+		}
+	}
+
+	entries, err := a.auditor.Query(&audit.Filter{Since: time.Now().Add(-d), User: user})
+	if err != nil {
+		return ErrorAnswer(err)
+	}
+
+	if len(entries) == 0 {
+		return TextAnswer("за " + q.Payload + " ничего не было")
+	}
+
+	if export {
+		return a.csv(entries, q.Payload)
+	}
+
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "аудит за %s, записей: %d\n", q.Payload, len(entries))
+
+	if len(entries) > auditLines {
+		entries = entries[len(entries)-auditLines:]
+	}
+
+	for _, e := range entries {
+		sb.WriteString("\n" + e.String())
+	}
+
+	return TextAnswer(sb.String())
+}
+
+// csv exports entries as a document.
+func (a *Audit) csv(entries []*audit.Entry, period string) *Answer {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+
+	w.Write([]string{"time", "source", "user", "chat", "answerer", "cmd", "payload", "outcome", "error", "msg"})
+
+	for _, e := range entries {
+		w.Write([]string{e.Time.Format(time.RFC3339), e.Source, e.User, strconv.FormatInt(e.Chat, 10), e.Answerer,
+			e.Cmd, e.Payload, e.Outcome, e.Error, e.Msg})
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return ErrorAnswer(err)
+	}
+
+	return DocumentAnswer(fmt.Sprintf("аудит за %s, записей: %d", period, len(entries)),
+		&File{Name: "audit-" + time.Now().Format("20060102-1504") + ".csv", Data: buf.Bytes()})
+}
@@ -0,0 +1,70 @@
+package answer
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"botik/cmd/botik/audit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failAnswerer struct{}
+
+func (f *failAnswerer) Check(user string, msg string, repl string) *Q {
+	return &Q{Msg: msg, User: user, Matched: msg == "fail", Cmd: "fail"}
+}
+
+func (f *failAnswerer) Process(q *Q) *Answer {
+	return ErrorAnswer(errors.New("mahno is down"))
+}
+
+func TestCheckAnswerAudit(t *testing.T) {
+	a := audit.New(slog.Default(), audit.NewMemoryLog(), 0)
+
+	am := New()
+	am.SetAuditor(a)
+	require.NoError(t, am.RegisterAnswer("light", &testAnswerer{name: "light"}, PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("fail", &failAnswerer{}, PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("audit", NewAudit(a), PriorityNormal))
+
+	am.CheckAnswer(&Request{User: "user", Chat: 10, Msg: "/light ON"})
+	am.CheckAnswer(&Request{User: "user", Chat: 10, Msg: "fail"})
+	am.CheckAnswer(&Request{User: "user", Chat: 10, Msg: "what"})
+
+	// audit is restricted even without access control
+	assert.Contains(t, am.CheckAnswer(&Request{User: "user", Msg: "audit"}).Msg, "нет прав")
+
+	entries, err := a.Query(&audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, audit.SourceTelegram, entries[0].Source)
+	assert.Equal(t, int64(10), entries[0].Chat)
+	assert.Equal(t, "light", entries[0].Answerer)
+	assert.Equal(t, "on", entries[0].Cmd)
+	assert.Equal(t, audit.OutcomeOk, entries[0].Outcome)
+
+	assert.Equal(t, audit.OutcomeError, entries[1].Outcome)
+	assert.Equal(t, "mahno is down", entries[1].Error)
+
+	assert.Equal(t, audit.OutcomeUnknown, entries[2].Outcome)
+	assert.Equal(t, audit.OutcomeDenied, entries[3].Outcome)
+
+	am.SetAccess(NewAccess(map[string][]string{"admin": {"*"}}, map[string][]string{"user": {"admin"}}))
+
+	ans := am.CheckAnswer(&Request{User: "user", Msg: "audit 1h user"})
+	assert.Contains(t, ans.Msg, "записей: 4")
+	assert.Contains(t, ans.Msg, "mahno is down")
+	assert.Contains(t, ans.Msg, `user "what" - unknown`)
+
+	ans = am.CheckAnswer(&Request{User: "user", Msg: "audit 1h csv"})
+	require.NotNil(t, ans.Document)
+	assert.Contains(t, ans.Msg, "записей: 5")
+	assert.True(t, strings.HasSuffix(ans.Document.Name, ".csv"))
+	assert.Contains(t, string(ans.Document.Data), "time,source,user,chat,answerer,cmd,payload,outcome,error,msg\n")
+	assert.Contains(t, string(ans.Document.Data), ",fail,fail,,error,mahno is down,fail\n")
+}
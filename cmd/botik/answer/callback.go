@@ -0,0 +1,120 @@
+package answer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"botik/cmd/botik/audit"
+)
+
+// callbackPrefix starts callback data of answerer buttons, alert buttons start with "al"
+const callbackPrefix = "ans"
+
+// Click is a press of inline button sent by answerer.
+type Click struct {
+	User string
+	Chat int64
+	// Message is id of the message with the button, answer with Edit set to it replaces the message
+	Message int
+	// Cmd is Q.Cmd of the command which has sent the button
+	Cmd  string
+	Data string
+}
+
+// Clickable answerer gets Button.Data of its inline buttons when they are pressed. Access is checked
+// for the command which has sent the buttons.
+type Clickable interface {
+	Click(c *Click) *Answer
+}
+
+// encodeCallback returns callback data of the button, telegram allows up to 64 bytes.
+func encodeCallback(answerer, cmd, data string) string {
+	return strings.Join([]string{callbackPrefix, answerer, cmd, data}, "|")
+}
+
+func parseCallback(data string) (string, string, string, bool) {
+	parts := strings.SplitN(data, "|", 4)
+
+	if len(parts) != 4 || parts[0] != callbackPrefix || parts[1] == "" {
+		return "", "", "", false
+	}
+
+	return parts[1], parts[2], parts[3], true
+}
+
+// bindButtons marks inline buttons of the answer with answerer and command, so a press goes back to them.
+func bindButtons(answerer, cmd string, a *Answer) {
+	if a == nil || a.Keyboard == nil || !a.Keyboard.Inline {
+		return
+	}
+
+	rows := make([][]Button, 0, len(a.Keyboard.Rows))
+
+	for _, r := range a.Keyboard.Rows {
+		row := slices.Clone(r)
+
+		for i, b := range row {
+			if b.URL == "" && b.Data != "" {
+				row[i].Data = encodeCallback(answerer, cmd, b.Data)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	k := *a.Keyboard
+	k.Rows = rows
+	a.Keyboard = &k
+}
+
+// Callback routes press of answerer button to the answerer, req.Msg is callback data and msgID is
+// the message with the button. It returns false if the button is not of an answerer.
+func (am *AnswerManager) Callback(req *Request, msgID int) (*Answer, bool) {
+	name, cmd, data, ok := parseCallback(req.Msg)
+
+	if !ok {
+		return nil, false
+	}
+
+	am.mx.RLock()
+	defer am.mx.RUnlock()
+
+	e := &audit.Entry{Source: audit.SourceCallback, User: req.User, Chat: req.Chat, Msg: req.Msg,
+		Answerer: name, Cmd: cmd, Payload: data}
+
+	c, ok := am.answerers[name].(Clickable)
+
+	if !ok {
+		e.Outcome = audit.OutcomeUnknown
+		am.auditor.Record(e)
+
+		return TextAnswer("кнопка больше не работает"), true
+	}
+
+	if len(req.Answerers) > 0 && !slices.Contains(req.Answerers, name) {
+		e.Outcome = audit.OutcomeDenied
+		am.auditor.Record(e)
+
+		return TextAnswer(fmt.Sprintf("в этом чате %s недоступно", name)), true
+	}
+
+	if !am.allowed(req.User, name, cmd) {
+		e.Outcome = audit.OutcomeDenied
+		am.auditor.Record(e)
+
+		return TextAnswer("нет прав"), true
+	}
+
+	a := c.Click(&Click{User: req.User, Chat: req.Chat, Message: msgID, Cmd: cmd, Data: data})
+
+	if a != nil && a.Err != nil {
+		e.Outcome = audit.OutcomeError
+		e.Error = a.Err.Error()
+	}
+
+	am.auditor.Record(e)
+	bindButtons(name, cmd, a)
+
+	return a, true
+}
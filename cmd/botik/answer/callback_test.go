@@ -0,0 +1,80 @@
+package answer
+
+import (
+	"log/slog"
+	"testing"
+
+	"botik/cmd/botik/audit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterAnswerer sends a counter with inline buttons.
+type counterAnswerer struct {
+	clicks []*Click
+}
+
+func (c *counterAnswerer) Check(user string, msg string, repl string) *Q {
+	return &Q{Msg: msg, User: user, Matched: msg == "counter", Prefix: "counter", Cmd: "counter"}
+}
+
+func (c *counterAnswerer) Process(q *Q) *Answer {
+	a := TextAnswer("0")
+	a.Keyboard = InlineKeyboard([]Button{{Text: "+1", Data: "inc"}, {Text: "docs", URL: "https://example.com"}})
+
+	return a
+}
+
+func (c *counterAnswerer) Click(cl *Click) *Answer {
+	c.clicks = append(c.clicks, cl)
+
+	return &Answer{Msg: "1", Edit: cl.Message}
+}
+
+func TestCallback(t *testing.T) {
+	log := audit.NewMemoryLog()
+	counter := new(counterAnswerer)
+
+	am := New()
+	am.SetAuditor(audit.New(slog.Default(), log, 0))
+	require.NoError(t, am.RegisterAnswer("counter", counter, PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("light", &testAnswerer{name: "light"}, PriorityNormal))
+
+	ans := am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "counter"})
+	require.NotNil(t, ans.Keyboard)
+
+	inc, docs := ans.Keyboard.Rows[0][0], ans.Keyboard.Rows[0][1]
+	assert.Equal(t, "ans|counter|counter|inc", inc.Data)
+	assert.Equal(t, "https://example.com", docs.URL)
+
+	// press the button
+	ans, ok := am.Callback(&Request{User: "user", Chat: 1, Msg: inc.Data}, 10)
+	require.True(t, ok)
+	assert.Equal(t, "1", ans.Msg)
+	assert.Equal(t, 10, ans.Edit)
+	require.Len(t, counter.clicks, 1)
+	assert.Equal(t, &Click{User: "user", Chat: 1, Message: 10, Cmd: "counter", Data: "inc"}, counter.clicks[0])
+
+	// alert buttons are not answerer buttons
+	_, ok = am.Callback(&Request{User: "user", Chat: 1, Msg: "al|mute|id|1h"}, 10)
+	assert.False(t, ok)
+
+	ans, _ = am.Callback(&Request{User: "user", Chat: 1, Msg: inc.Data, Answerers: []string{"light"}}, 10)
+	assert.Contains(t, ans.Msg, "недоступно")
+
+	am.SetAccess(NewAccess(map[string][]string{"guest": {"light"}}, map[string][]string{"user": {"guest"}}))
+	ans, _ = am.Callback(&Request{User: "user", Chat: 1, Msg: inc.Data}, 10)
+	assert.Equal(t, "нет прав", ans.Msg)
+
+	ans, _ = am.Callback(&Request{User: "user", Chat: 1, Msg: "ans|light|STATUS|x"}, 10)
+	assert.Equal(t, "кнопка больше не работает", ans.Msg)
+
+	assert.Len(t, counter.clicks, 1)
+
+	entries, err := log.Query(&audit.Filter{Answerer: "counter"})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, audit.SourceCallback, entries[1].Source)
+	assert.Equal(t, audit.OutcomeDenied, entries[3].Outcome)
+}
@@ -1,7 +1,6 @@
 package answer
 
 import (
-	"botik/internal/util"
 	"log/slog"
 	"strings"
 )
@@ -18,19 +17,18 @@ func NewCamera(logger *slog.Logger, img string) *Camera {
 	}
 }
 
-func (cam *Camera) Check(user string, msg string, repl string) (q *Q) {
-	q = &Q{Msg: msg, User: strings.ToLower(user)}
+var cameraGrammar = Grammar{
+	{Cmd: "camera", Phrases: []string{"cam", "камера"}},
+}
 
-	words := q.Words()
+func (cam *Camera) Check(user string, msg string, repl string) (q *Q) {
+	return cameraGrammar.Check(strings.ToLower(user), msg, repl)
+}
 
-	if util.IsInArray(words[0], "камера", "cam") {
-		q.Matched = true
-		q.Prefix = words[0]
-		q.Cmd = "camera"
-		return
+func (cam *Camera) Commands() []Command {
+	return []Command{
+		{Name: "cam", Aliases: []string{"камера"}, Cmd: "camera", Description: "снимок с камеры", DescriptionEn: "camera snapshot"},
 	}
-
-	return
 }
 
 func (cam *Camera) Process(q *Q) *Answer {
@@ -0,0 +1,212 @@
+package answer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"botik/cmd/botik/audit"
+	"botik/internal/util"
+)
+
+// telegram command menu accepts only such names
+var menuName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+var helpWords = []string{"help", "помощь", "start"}
+
+// Command describes a command of answerer for /help and telegram command menu.
+type Command struct {
+	// Name is the command word, it is published in command menu if it is latin, like "mute"
+	Name    string
+	Aliases []string
+	// Cmd is Q.Cmd of the command, it is used to check access
+	Cmd string
+	// Args is argument syntax, like "[длительность]"
+	Args          string
+	Description   string
+	DescriptionEn string
+}
+
+// Documented answerer declares its commands.
+type Documented interface {
+	Commands() []Command
+}
+
+// AnswererCommand is a command with the name of its answerer.
+type AnswererCommand struct {
+	Answerer string
+	Command
+}
+
+var helpCommand = Command{
+	Name:          "help",
+	Aliases:       []string{"помощь"},
+	Args:          "[команда]",
+	Description:   "список команд или описание команды",
+	DescriptionEn: "list of commands or command usage",
+}
+
+var cancelCommand = Command{
+	Name:          "cancel",
+	Aliases:       []string{"отмена"},
+	Description:   "отменить текущий вопрос бота",
+	DescriptionEn: "cancel the current question of the bot",
+}
+
+// Menu tells if command can be published in telegram command menu.
+func (c *Command) Menu() bool {
+	return menuName.MatchString(c.Name)
+}
+
+// Desc returns description in language lang, russian is default.
+func (c *Command) Desc(lang string) string {
+	if strings.HasPrefix(lang, "en") && c.DescriptionEn != "" {
+		return c.DescriptionEn
+	}
+
+	return c.Description
+}
+
+// Syntax is a command with arguments, like "/mute [длительность]".
+func (c *Command) Syntax() string {
+	s := c.Name
+
+	if c.Menu() {
+		s = "/" + s
+	}
+
+	if c.Args != "" {
+		s += " " + c.Args
+	}
+
+	return s
+}
+
+// Usage is a full description of the command.
+func (c *Command) Usage(lang string) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "%s\n%s", c.Syntax(), c.Desc(lang))
+
+	if len(c.Aliases) > 0 {
+		fmt.Fprintf(sb, "\nтакже: %s", strings.Join(c.Aliases, ", "))
+	}
+
+	return sb.String()
+}
+
+func (c *Command) is(word string) bool {
+	return strings.EqualFold(c.Name, word) || util.IsInArray(strings.ToLower(word), c.Aliases...)
+}
+
+// Commands returns declared commands of all answerers in order of priority, then registration.
+func (am *AnswerManager) Commands() []*AnswererCommand {
+	am.mx.RLock()
+	defer am.mx.RUnlock()
+
+	return am.commands()
+}
+
+func (am *AnswerManager) commands() []*AnswererCommand {
+	res := make([]*AnswererCommand, 0)
+
+	for _, r := range am.ordered {
+		if d, ok := r.ans.(Documented); ok {
+			for _, c := range d.Commands() {
+				res = append(res, &AnswererCommand{Answerer: r.name, Command: c})
+			}
+		}
+	}
+
+	return res
+}
+
+// MenuCommands returns commands for telegram command menu, /help and /cancel are the last ones.
+func (am *AnswerManager) MenuCommands() []Command {
+	res := make([]Command, 0)
+	seen := make(map[string]bool)
+
+	for _, c := range append(am.Commands(), &AnswererCommand{Command: helpCommand}, &AnswererCommand{Command: cancelCommand}) {
+		if c.Menu() && !seen[c.Name] {
+			seen[c.Name] = true
+			res = append(res, c.Command)
+		}
+	}
+
+	return res
+}
+
+// isHelp tells if message asks for help and returns the topic, "help mute" asks about mute.
+func isHelp(msg string) (string, bool) {
+	words := strings.Fields(strings.ToLower(msg))
+
+	if len(words) == 0 || !util.IsInArray(words[0], helpWords...) {
+		return "", false
+	}
+
+	if len(words) > 1 {
+		return strings.TrimLeft(words[1], "/"), true
+	}
+
+	return "", true
+}
+
+// help lists commands available to the user in the chat or describes one command.
+func (am *AnswerManager) help(req *Request, topic string) *Answer {
+	e := &audit.Entry{Source: audit.SourceTelegram, User: req.User, Chat: req.Chat, Msg: req.Msg, Answerer: "help", Payload: topic}
+	defer am.auditor.Record(e)
+
+	var available []*AnswererCommand
+
+	for _, c := range am.commands() {
+		if len(req.Answerers) > 0 && !util.IsInArray(c.Answerer, req.Answerers...) {
+			continue
+		}
+
+		if am.allowed(req.User, c.Answerer, c.Cmd) {
+			available = append(available, c)
+		}
+	}
+
+	if topic != "" {
+		for _, c := range available {
+			if c.is(topic) {
+				return TextAnswer(am.usage(c, req.Lang))
+			}
+		}
+
+		for _, c := range []*Command{&helpCommand, &cancelCommand} {
+			if c.is(topic) {
+				return TextAnswer(c.Usage(req.Lang))
+			}
+		}
+
+		return TextAnswer(fmt.Sprintf("нет такой команды: %s", topic))
+	}
+
+	if len(available) == 0 {
+		return TextAnswer("нет доступных команд")
+	}
+
+	sb := new(strings.Builder)
+	sb.WriteString("команды:\n")
+
+	for _, c := range available {
+		fmt.Fprintf(sb, "%s - %s\n", c.Syntax(), c.Desc(req.Lang))
+	}
+
+	fmt.Fprintf(sb, "\n%s - %s", helpCommand.Syntax(), helpCommand.Desc(req.Lang))
+	fmt.Fprintf(sb, "\n%s - %s", cancelCommand.Syntax(), cancelCommand.Desc(req.Lang))
+
+	return TextAnswer(sb.String())
+}
+
+// usage is Usage of the command with roles which can run it, if access control is on.
+func (am *AnswerManager) usage(c *AnswererCommand, lang string) string {
+	s := c.Usage(lang)
+
+	if roles := am.access.RolesFor(c.Answerer, c.Cmd); len(roles) > 0 {
+		s += "\nнужна роль: " + strings.Join(roles, " или ")
+	}
+
+	return s
+}
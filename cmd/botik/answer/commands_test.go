@@ -0,0 +1,81 @@
+package answer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// every declared command and alias must be dispatched to its answerer and command
+func TestCommandsMatch(t *testing.T) {
+	am := newTestManager(t)
+
+	cmds := am.Commands()
+	require.NotEmpty(t, cmds)
+
+	for _, c := range cmds {
+		for _, word := range append([]string{c.Name}, c.Aliases...) {
+			best, ambiguous := am.match(&Request{User: "user", Msg: word})
+
+			require.Empty(t, ambiguous, word)
+			require.NotNil(t, best, word)
+			assert.Equal(t, c.Answerer, best.name, word)
+			assert.Equal(t, c.Cmd, best.q.Cmd, word)
+		}
+	}
+}
+
+func TestMenuCommands(t *testing.T) {
+	am := newTestManager(t)
+
+	menu := am.MenuCommands()
+	names := make([]string, 0, len(menu))
+
+	for _, c := range menu {
+		assert.True(t, c.Menu(), c.Name)
+		assert.NotEmpty(t, c.Desc("ru"), c.Name)
+		assert.NotEmpty(t, c.Desc("en"), c.Name)
+		names = append(names, c.Name)
+	}
+
+	assert.Contains(t, names, "mute")
+	assert.Contains(t, names, "light")
+	assert.NotContains(t, names, "включи")
+	assert.Equal(t, []string{"help", "cancel"}, names[len(names)-2:])
+}
+
+func TestHelp(t *testing.T) {
+	am := newTestManager(t)
+
+	ans := am.CheckAnswer(&Request{User: "user", Msg: "/help"})
+	assert.Contains(t, ans.Msg, "/mute [длительность] - заглушить алерт")
+	assert.Contains(t, ans.Msg, "включи <что>")
+	// audit is restricted
+	assert.NotContains(t, ans.Msg, "/audit")
+
+	ans = am.CheckAnswer(&Request{User: "user", Msg: "help", Lang: "en"})
+	assert.Contains(t, ans.Msg, "/mute [длительность] - mute alert")
+
+	ans = am.CheckAnswer(&Request{User: "user", Msg: "помощь выкл"})
+	assert.Equal(t, "/mute [длительность]\nзаглушить алерт, ответом на сообщение с алертом\nтакже: выкл", ans.Msg)
+
+	assert.Contains(t, am.CheckAnswer(&Request{User: "user", Msg: "help what"}).Msg, "нет такой команды")
+	assert.Contains(t, am.CheckAnswer(&Request{User: "user", Msg: "help audit"}).Msg, "нет такой команды")
+
+	// only commands allowed to the user in the chat
+	am.SetAccess(NewAccess(map[string][]string{"admin": {"*"}, "guest": {"light:STATUS", "alerts"}},
+		map[string][]string{"root": {"admin"}, "user": {"guest"}}))
+
+	ans = am.CheckAnswer(&Request{User: "user", Msg: "help", Answerers: []string{"light"}})
+	assert.Contains(t, ans.Msg, "/light - состояние света")
+	assert.NotContains(t, ans.Msg, "включи")
+	assert.NotContains(t, ans.Msg, "/mute")
+
+	ans = am.CheckAnswer(&Request{User: "root", Msg: "help audit"})
+	assert.Contains(t, ans.Msg, "нужна роль: admin")
+
+	// roles are taken from access config
+	ans = am.CheckAnswer(&Request{User: "user", Msg: "help light"})
+	assert.Contains(t, ans.Msg, "нужна роль: admin или guest")
+}
@@ -0,0 +1,164 @@
+package answer
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) *AnswerManager {
+	am := New()
+	require.NoError(t, am.RegisterAnswer("light", NewLight(slog.Default(), "http://localhost:8080"), PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("bp", NewInflux("http://localhost:8086"), PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("cam", NewCamera(slog.Default(), "/tmp/cam.jpg"), PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("alerts", NewAlerts(slog.Default(), nil), PriorityHigh))
+	require.NoError(t, am.RegisterAnswer("audit", NewAudit(nil), PriorityHigh))
+
+	return am
+}
+
+func TestDispatch(t *testing.T) {
+	am := newTestManager(t)
+
+	tests := []struct {
+		msg      string
+		answerer string
+		cmd      string
+	}{
+		{"light", "light", STATUS},
+		{"/light", "light", STATUS},
+		{"свет", "light", STATUS},
+		{"статус", "light", STATUS},
+		{"включи свет на кухне", "light", ON},
+		{"включить весь свет", "light", ON},
+		{"выключи свет", "light", OFF},
+		{"выключить везде", "light", OFF},
+		{"спать", "light", NIGHT},
+		{"ночной режим", "light", NIGHT},
+		{"ночь", "light", NIGHT},
+		{"день", "light", DAY},
+		{"жди", "light", NOBODY_HOME},
+		{"все ушли", "light", NOBODY_HOME},
+		{"один дома", "light", NOBODY_HOME},
+		{"давление", "bp", BP},
+		{"bp 120 80", "bp", BP},
+		{"давление мама", "bp", BP_OTHER},
+		{"вес", "bp", WEIGHT},
+		{"weight 80", "bp", WEIGHT},
+		{"камера", "cam", "camera"},
+		{"cam", "cam", "camera"},
+		{"mute", "alerts", "mute"},
+		{"выкл 2h", "alerts", "mute"},
+		{"ack", "alerts", "ack"},
+		{"принял", "alerts", "ack"},
+		{"unmute", "alerts", "unmute"},
+		{"history", "alerts", "history"},
+		{"история 2d", "alerts", "history"},
+		{"silences", "alerts", "silences"},
+		{"тишина", "alerts", "silences"},
+		{"alerts", "alerts", "alerts"},
+		{"алерты", "alerts", "alerts"},
+		{"audit", "audit", "audit"},
+		{"аудит 1h", "audit", "audit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			// the winner must not depend on the run
+			for i := 0; i < 10; i++ {
+				best, ambiguous := am.match(&Request{User: "user", Msg: tt.msg})
+
+				require.Empty(t, ambiguous)
+				require.NotNil(t, best)
+				assert.Equal(t, tt.answerer, best.name)
+				assert.Equal(t, tt.cmd, best.q.Cmd)
+			}
+		})
+	}
+
+	for _, msg := range []string{"", "/", "привет", "что нового"} {
+		best, ambiguous := am.match(&Request{User: "user", Msg: msg})
+		assert.Nil(t, best, msg)
+		assert.Empty(t, ambiguous, msg)
+	}
+}
+
+// scoreAnswerer matches messages starting with its word with the given score.
+type scoreAnswerer struct {
+	word  string
+	score int
+}
+
+func (s *scoreAnswerer) Check(user string, msg string, repl string) *Q {
+	q := &Q{Msg: msg, User: user}
+
+	if LongestPrefix(msg, s.word) != "" {
+		q.Matched, q.Prefix, q.Cmd, q.Score = true, s.word, s.word, s.score
+	}
+
+	return q
+}
+
+func (s *scoreAnswerer) Process(q *Q) *Answer {
+	return TextAnswer("ok " + q.Cmd)
+}
+
+func TestDispatchPriority(t *testing.T) {
+	tests := []struct {
+		name      string
+		answerers []*scoreAnswerer
+		priority  []int
+		msg       string
+		want      string
+		ambiguous bool
+	}{
+		{"longer prefix", []*scoreAnswerer{{word: "выкл"}, {word: "выключи"}}, []int{0, 0}, "выключи свет", "b", false},
+		{"whole word", []*scoreAnswerer{{word: "свет"}, {word: "свет"}}, []int{0, 0}, "светофор", "", true},
+		{"priority", []*scoreAnswerer{{word: "свет"}, {word: "свет"}}, []int{0, 1}, "свет", "b", false},
+		{"score", []*scoreAnswerer{{word: "свет", score: 100}, {word: "свет"}}, []int{0, 1}, "свет", "a", false},
+		{"ambiguous", []*scoreAnswerer{{word: "свет"}, {word: "свет"}}, []int{1, 1}, "свет", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := New()
+
+			for i, a := range tt.answerers {
+				require.NoError(t, am.RegisterAnswer(string(rune('a'+i)), a, tt.priority[i]))
+			}
+
+			best, ambiguous := am.match(&Request{Msg: tt.msg})
+
+			if tt.ambiguous {
+				assert.Nil(t, best)
+				assert.Len(t, ambiguous, len(tt.answerers))
+
+				return
+			}
+
+			require.NotNil(t, best)
+			assert.Equal(t, tt.want, best.name)
+		})
+	}
+}
+
+func TestCheckAnswerAmbiguous(t *testing.T) {
+	am := New()
+	require.NoError(t, am.RegisterAnswer("light", &testAnswerer{name: "x"}, PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("cam", &testAnswerer{name: "x"}, PriorityNormal))
+
+	ans := am.CheckAnswer(&Request{User: "user", Msg: "x on"})
+	assert.Contains(t, ans.Msg, "уточните")
+	assert.Contains(t, ans.Msg, "light (on)")
+	assert.Contains(t, ans.Msg, "cam (on)")
+
+	assert.Contains(t, am.CheckAnswer(&Request{User: "user", Msg: "/"}).Msg, "не знаю")
+}
+
+func TestSpecificity(t *testing.T) {
+	assert.Greater(t, (&Q{Msg: "выключи свет", Prefix: "выключи"}).Specificity(), (&Q{Msg: "выключи свет", Prefix: "выкл"}).Specificity())
+	assert.Greater(t, (&Q{Msg: "свет", Prefix: "свет"}).Specificity(), (&Q{Msg: "светофор", Prefix: "свет"}).Specificity())
+	assert.Equal(t, 42, (&Q{Msg: "свет", Prefix: "свет", Score: 42}).Specificity())
+}
@@ -0,0 +1,241 @@
+package answer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// slot kinds
+const (
+	// SlotEntity is a word from Slot.Dict, its value is the entity name
+	SlotEntity = "entity"
+	// SlotDuration is like "2h", "10 минут" or "на час", value is like "2h" or "10m"
+	SlotDuration = "duration"
+	SlotNumber   = "number"
+	// SlotClock is time of day like "18:30", value is "18:30"
+	SlotClock = "clock"
+	// SlotWord is the next word
+	SlotWord = "word"
+	// SlotText is all the rest of the message
+	SlotText = "text"
+)
+
+// fillers are words skipped between command and its arguments
+var fillers = []string{"в", "во", "на", "у", "за", "через", "по", "до", "для", "мне", "пожалуйста", "плиз"}
+
+// Dict maps entity names to their words in any case form, like "kitchen": {"кухня"} matches "на кухне".
+type Dict map[string][]string
+
+// Find returns the first token which is a word of an entity.
+func (d Dict) Find(tokens []Token) (string, int, bool) {
+	for i, t := range tokens {
+		best, name := matchNone, ""
+
+		for n, words := range d {
+			for _, w := range words {
+				if m, _ := matchWord(t, wordToken(w)); m > best || (m == best && m != matchNone && n < name) {
+					best, name = m, n
+				}
+			}
+		}
+
+		if best != matchNone {
+			return name, i, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// Slot is an argument of the command.
+type Slot struct {
+	Name string
+	Kind string
+	Dict Dict
+}
+
+// Rule is a command of answerer. It matches the message starting with one of the phrases, any case form
+// or a typo of phrase words is fine. Slots are taken from the rest of the message.
+type Rule struct {
+	Cmd     string
+	Phrases []string
+	// Alone rule matches only the phrase without other words
+	Alone bool
+	Slots []Slot
+}
+
+// Grammar is a list of rules of answerer.
+type Grammar []*Rule
+
+// Parsed is a message matched by a rule.
+type Parsed struct {
+	Rule *Rule
+	// Prefix is the matched phrase as it is in the message
+	Prefix string
+	Score  int
+	Slots  map[string]string
+	// Rest is tokens not taken by phrase and slots
+	Rest []Token
+}
+
+func wordToken(w string) Token {
+	text := normalize(w)
+	return Token{Raw: w, Text: text, Stem: Stem(text)}
+}
+
+// matchPhrase matches phrase with the beginning of tokens, it returns score and the number of tokens taken.
+// Exact word is better than other form of the word and it is better than a typo.
+func matchPhrase(tokens []Token, phrase string) (int, int) {
+	words := strings.Fields(phrase)
+
+	if len(words) > len(tokens) {
+		return 0, 0
+	}
+
+	score := 0
+
+	for i, w := range words {
+		m, d := matchWord(tokens[i], wordToken(w))
+		n := utf8.RuneCountInString(w)
+
+		switch m {
+		case matchExact:
+			score += 2*n + 2
+		case matchStem:
+			score += 2*n + 1
+		case matchFuzzy:
+			score += 2*n - 2*d
+		default:
+			return 0, 0
+		}
+	}
+
+	return score, len(words)
+}
+
+// Parse finds the best rule for the message, nil if no rule matches.
+func (g Grammar) Parse(msg string) *Parsed {
+	tokens := Tokenize(msg)
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var best *Parsed
+
+	for _, r := range g {
+		for _, ph := range r.Phrases {
+			score, n := matchPhrase(tokens, ph)
+
+			if n == 0 || (r.Alone && n != len(tokens)) {
+				continue
+			}
+
+			if best == nil || score > best.Score {
+				raw := make([]string, 0, n)
+				for _, t := range tokens[:n] {
+					raw = append(raw, t.Raw)
+				}
+
+				best = &Parsed{Rule: r, Prefix: strings.Join(raw, " "), Score: score, Rest: tokens[n:]}
+			}
+		}
+	}
+
+	if best != nil {
+		best.fill()
+	}
+
+	return best
+}
+
+// fill takes slots from the rest of the message.
+func (p *Parsed) fill() {
+	p.Slots = make(map[string]string)
+
+	rest := make([]Token, 0, len(p.Rest))
+	for _, t := range p.Rest {
+		if !isFiller(t.Text) {
+			rest = append(rest, t)
+		}
+	}
+
+	take := func(from, to int) {
+		rest = append(rest[:from:from], rest[to:]...)
+	}
+
+	for _, s := range p.Rule.Slots {
+		switch s.Kind {
+		case SlotEntity:
+			if name, i, ok := s.Dict.Find(rest); ok {
+				p.Slots[s.Name] = name
+				take(i, i+1)
+			}
+
+		case SlotDuration:
+			if d, from, to, ok := ParseDuration(rest); ok {
+				p.Slots[s.Name] = FormatDuration(d)
+				take(from, to)
+			}
+
+		case SlotNumber:
+			for i, t := range rest {
+				if _, ok := ParseNumber(t.Text); ok {
+					p.Slots[s.Name] = t.Text
+					take(i, i+1)
+					break
+				}
+			}
+
+		case SlotClock:
+			for i, t := range rest {
+				if h, m, ok := ParseClock(t.Text); ok {
+					p.Slots[s.Name] = fmt.Sprintf("%02d:%02d", h, m)
+					take(i, i+1)
+					break
+				}
+			}
+
+		case SlotWord:
+			if len(rest) > 0 {
+				p.Slots[s.Name] = rest[0].Text
+				take(0, 1)
+			}
+
+		case SlotText:
+			if len(rest) > 0 {
+				p.Slots[s.Name] = strings.Join(Texts(rest), " ")
+				rest = rest[:0]
+			}
+		}
+	}
+
+	p.Rest = rest
+}
+
+func isFiller(s string) bool {
+	for _, f := range fillers {
+		if s == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check makes Q for answerer from the best rule, Q.Score is the score of the match.
+func (g Grammar) Check(user string, msg string, repl string) *Q {
+	q := &Q{Msg: msg, User: user, Repl: repl}
+
+	if p := g.Parse(msg); p != nil {
+		q.Matched = true
+		q.Prefix = p.Prefix
+		q.Cmd = p.Rule.Cmd
+		q.Score = p.Score
+		q.Slots = p.Slots
+		q.Rest = Texts(p.Rest)
+	}
+
+	return q
+}
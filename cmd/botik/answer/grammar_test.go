@@ -0,0 +1,106 @@
+package answer
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrammar(t *testing.T) {
+	g := Grammar{
+		{Cmd: "status", Phrases: []string{"status"}, Alone: true},
+		{Cmd: "on", Phrases: []string{"включи"}, Slots: []Slot{
+			{Name: "room", Kind: SlotEntity, Dict: Dict{"kitchen": {"кухня"}, "corridor": {"коридор"}}},
+			{Name: "for", Kind: SlotDuration},
+		}},
+		{Cmd: "night", Phrases: []string{"ночной режим"}, Slots: []Slot{{Name: "at", Kind: SlotClock}}},
+		{Cmd: "note", Phrases: []string{"заметка"}, Slots: []Slot{{Name: "n", Kind: SlotNumber}, {Name: "text", Kind: SlotText}}},
+	}
+
+	tests := []struct {
+		msg   string
+		cmd   string
+		slots map[string]string
+		rest  []string
+	}{
+		{"status", "status", map[string]string{}, []string{}},
+		{"включи свет на кухне", "on", map[string]string{"room": "kitchen"}, []string{"свет"}},
+		{"Включите, пожалуйста, в корридоре на полчаса", "on", map[string]string{"room": "corridor", "for": "30m"}, []string{}},
+		{"вкючи кухню через 10 минут", "on", map[string]string{"room": "kitchen", "for": "10m"}, []string{}},
+		{"ночной режим в 23:30", "night", map[string]string{"at": "23:30"}, []string{}},
+		{"заметка 5,5 купить хлеб", "note", map[string]string{"n": "5,5", "text": "купить хлеб"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			p := g.Parse(tt.msg)
+			require.NotNil(t, p)
+			assert.Equal(t, tt.cmd, p.Rule.Cmd)
+			assert.Equal(t, tt.slots, p.Slots)
+			assert.Equal(t, tt.rest, Texts(p.Rest))
+		})
+	}
+
+	for _, msg := range []string{"", "status now", "ночной", "привет"} {
+		assert.Nil(t, g.Parse(msg), msg)
+	}
+
+	// exact word is better than other form and typo
+	exact, _ := matchPhrase(Tokenize("включи"), "включи")
+	form, _ := matchPhrase(Tokenize("включить"), "включи")
+	typo, _ := matchPhrase(Tokenize("вкючи"), "включи")
+	assert.Greater(t, exact, form)
+	assert.Greater(t, form, typo)
+}
+
+func TestAnswerersGrammar(t *testing.T) {
+	am := newTestManager(t)
+
+	tests := []struct {
+		msg      string
+		answerer string
+		cmd      string
+		payload  string
+		slots    map[string]string
+	}{
+		{"включи свет на кухне", "light", ON, "", map[string]string{"item": "kitchen"}},
+		{"вкючи свет в спальне", "light", ON, "", map[string]string{"item": "light_room"}},
+		{"выключи свет у Макса", "light", OFF, "", map[string]string{"item": "max"}},
+		{"выключи свет на улице", "light", OFF, "lights_out", map[string]string{"group": "lights_out"}},
+		{"выключи весь свет", "light", OFF, "lights", map[string]string{"group": "lights"}},
+		{"выкл на 2 часа", "alerts", "mute", "2h", nil},
+		{"mute через полчаса", "alerts", "mute", "30m", nil},
+		{"mute abc", "alerts", "mute", "abc", nil},
+		{"ack 12ab", "alerts", "ack", "12ab", nil},
+		{"история за неделю", "alerts", "history", "7d", nil},
+		{"истории", "alerts", "history", "24h", nil},
+		{"давление мама", "bp", BP_OTHER, "мама", nil},
+		{"давление 120 80", "bp", BP, "", map[string]string{"sys": "120", "dia": "80"}},
+		{"аудит за час", "audit", "audit", "1h", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			best, ambiguous := am.match(&Request{User: "user", Msg: tt.msg})
+
+			require.Empty(t, ambiguous)
+			require.NotNil(t, best)
+			assert.Equal(t, tt.answerer, best.name)
+			assert.Equal(t, tt.cmd, best.q.Cmd)
+			assert.Equal(t, tt.payload, best.q.Payload)
+
+			for k, v := range tt.slots {
+				assert.Equal(t, v, best.q.Slots[k], k)
+			}
+		})
+	}
+
+	l := NewLight(slog.Default(), "http://localhost:8080")
+	assert.Equal(t, []string{"csv"}, NewAudit(nil).Check("user", "аудит 1h csv", "").Rest)
+
+	// room is asked if it is not found
+	ans := l.Continue(&Session{Q: &Q{Cmd: ON}}, "на чердаке")
+	assert.NotNil(t, ans.Ask)
+}
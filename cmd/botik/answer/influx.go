@@ -6,22 +6,26 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	BP     = "bp"
-	WEIGHT = "weight"
-	DB     = "bio"
+	BP = "bp"
+	// BP_OTHER is reading pressure of another user
+	BP_OTHER = "bp_other"
+	WEIGHT   = "weight"
+	DB       = "bio"
 )
 
 type Influx struct {
 	api    api.InfluxHttpApi
 	logger *slog.Logger
 	days   uint16
+	// users whose pressure can be read, any name if empty
+	users []string
 }
 
 func NewInflux(addr string) *Influx {
@@ -33,6 +37,31 @@ func NewInflux(addr string) *Influx {
 	}
 }
 
+// SetUsers sets known users, pressure of other names is not queried.
+func (i *Influx) SetUsers(names ...string) {
+	i.users = make([]string, 0, len(names))
+
+	for _, n := range names {
+		i.users = append(i.users, strings.ToLower(n))
+	}
+}
+
+// user finds known user by name in any case form, "мамы" is "мама".
+func (i *Influx) user(name string) (string, bool) {
+	if len(i.users) == 0 {
+		return name, true
+	}
+
+	users := make(Dict)
+	for _, u := range i.users {
+		users[u] = []string{u}
+	}
+
+	u, _, ok := users.Find(Tokenize(name))
+
+	return u, ok
+}
+
 func getNano() int64 {
 	return time.Now().Round(time.Minute).UnixNano()
 }
@@ -43,96 +72,134 @@ type Pressure struct {
 	Dia  uint16
 }
 
-func (i *Influx) Check(user string, msg string, repl string) (q *Q) {
-	q = &Q{Msg: msg, User: strings.ToLower(user)}
+var influxGrammar = Grammar{
+	{Cmd: BP, Phrases: []string{"bp", "давление"}, Slots: []Slot{{Name: "sys", Kind: SlotNumber}, {Name: "dia", Kind: SlotNumber}}},
+	{Cmd: WEIGHT, Phrases: []string{"weight", "вес"}, Slots: []Slot{{Name: "weight", Kind: SlotNumber}}},
+}
 
-	words := q.Words()
+func (i *Influx) Check(user string, msg string, repl string) (q *Q) {
+	q = influxGrammar.Check(strings.ToLower(user), msg, repl)
 
-	if util.IsInArray(words[0], "давление", "bp") {
-		q.Matched = true
-		q.Prefix = words[0]
-		q.Cmd = BP
-		return
+	// "давление мама" or "давление для мамы" is pressure of other user
+	if q.Cmd == BP && q.Slots["sys"] == "" && len(q.Rest) == 1 {
+		q.Cmd = BP_OTHER
+		q.Payload = q.Rest[0]
 	}
 
-	if util.IsInArray(words[0], "вес", "weight") {
-		q.Matched = true
-		q.Prefix = words[0]
-		q.Cmd = WEIGHT
-		return
-	}
+	return q
+}
 
-	return
+func (i *Influx) Commands() []Command {
+	return []Command{
+		{Name: "bp", Aliases: []string{"давление"}, Cmd: BP, Args: "[верхнее нижнее [заметка]]",
+			Description: "записать давление или показать последние", DescriptionEn: "record blood pressure or show the last ones"},
+		{Name: "weight", Aliases: []string{"вес"}, Cmd: WEIGHT, Args: "<вес>", Description: "записать вес", DescriptionEn: "record weight"},
+	}
 }
 
 func (i *Influx) Process(q *Q) *Answer {
-	words := q.Words()
-
 	switch q.Cmd {
+	case BP_OTHER:
+		return i.pressureAnswer(q.Payload)
+
 	case BP:
-		if len(words) == 1 {
-			p, err := i.getPressure(q.User, 50)
-			if err == nil {
-				res := fmt.Sprintf("Давление за последние %d дней для %s\n\n", i.days, q.User)
-				for _, pp := range p {
-					res += pp.String() + "\n"
-				}
-				return TextAnswer(res)
-			} else {
-				i.logger.Error("error getting pressure", "error", err)
-				return TextAnswer(err.Error())
-			}
+		if len(q.Slots) == 0 && len(q.Rest) == 0 {
+			return i.pressureAnswer(q.User)
 		}
 
-		if len(words) >= 3 {
-			var note string
-
-			sys, err := strconv.ParseInt(words[1], 10, 16)
-			if err != nil {
-				i.logger.Error("parse error", "error", err)
-				return TextAnswer(err.Error())
-			}
-			dia, err := strconv.ParseInt(words[2], 10, 16)
-			if err != nil {
-				i.logger.Error("parse error", "error", err)
-				return TextAnswer(err.Error())
-			}
-			if len(words) > 3 {
-				note = strings.Join(words[3:], " ")
-			}
-			if err := i.sendBP(q.User, uint16(sys), uint16(dia), note); err != nil {
-				i.logger.Error("send error", "error", err)
-				return TextAnswer("ошибка " + err.Error())
-			} else {
-				return TextAnswer(fmt.Sprintf("записано давление %d/%d", sys, dia))
-			}
+		sys, ok1 := pressureValue(q.Slots["sys"])
+		dia, ok2 := pressureValue(q.Slots["dia"])
 
+		if !ok1 || !ok2 {
+			return TextAnswer("использование: \"давление\" или \"давление 120 80\"")
 		}
 
-		return TextAnswer("использование: \"давление\" или \"давление 120 80\"")
+		if err := i.sendBP(q.User, sys, dia, bpNote(q)); err != nil {
+			i.logger.Error("send error", "error", err)
+			return ErrorAnswer(err)
+		}
+
+		return TextAnswer(fmt.Sprintf("записано давление %d/%d", sys, dia))
 
 	case WEIGHT:
-		if len(words) == 2 {
-			w, err := strconv.ParseFloat(strings.ReplaceAll(words[1], ",", "."), 10)
-			if err != nil {
-				i.logger.Error("parse error", "error", err)
-				return TextAnswer(err.Error())
-			}
-			if err := i.sendWeight(q.User, w, 0); err != nil {
-				i.logger.Error("send error", "error", err)
-				return TextAnswer("ошибка " + err.Error())
-			} else {
-				return TextAnswer(fmt.Sprintf("записан вес %.1f", w))
-			}
+		w, ok := ParseNumber(q.Slots["weight"])
+
+		if !ok || w <= 0 {
+			return TextAnswer("использование: \"вес \" или \"вес 95.2\"")
+		}
 
+		if err := i.sendWeight(q.User, w, 0); err != nil {
+			i.logger.Error("send error", "error", err)
+			return ErrorAnswer(err)
 		}
-		return TextAnswer("использование: \"вес \" или \"вес 95.2\"")
+
+		return TextAnswer(fmt.Sprintf("записан вес %.1f", w))
 
 	default:
 		return TextAnswer("invalid command " + q.Cmd)
 	}
 }
 
+func pressureValue(s string) (uint16, bool) {
+	v, ok := ParseNumber(s)
+
+	if !ok || v <= 0 || v > math.MaxUint16 || v != math.Trunc(v) {
+		return 0, false
+	}
+
+	return uint16(v), true
+}
+
+// bpNote is the message without command and pressure values, like "после зарядки" in "давление 130 85 после зарядки".
+func bpNote(q *Q) string {
+	tokens := Tokenize(q.Msg)
+	tokens = tokens[min(len(Tokenize(q.Prefix)), len(tokens)):]
+	taken := map[string]bool{q.Slots["sys"]: true, q.Slots["dia"]: true}
+
+	var words []string
+	onlyFillers := true
+
+	for _, t := range tokens {
+		if taken[t.Text] {
+			delete(taken, t.Text)
+			continue
+		}
+
+		onlyFillers = onlyFillers && isFiller(t.Text)
+		words = append(words, t.Raw)
+	}
+
+	// "давление 120 на 80" has no note
+	if onlyFillers {
+		return ""
+	}
+
+	return strings.Join(words, " ")
+}
+
+func (i *Influx) pressureAnswer(user string) *Answer {
+	name, ok := i.user(user)
+
+	if !ok {
+		return TextAnswer(fmt.Sprintf("нет такого пользователя: %s", user))
+	}
+
+	user = name
+
+	p, err := i.getPressure(user, 50)
+	if err != nil {
+		i.logger.Error("error getting pressure", "error", err)
+		return TextAnswer(err.Error())
+	}
+
+	res := fmt.Sprintf("Давление за последние %d дней для %s\n\n", i.days, user)
+	for _, pp := range p {
+		res += pp.String() + "\n"
+	}
+
+	return TextAnswer(res)
+}
+
 func (p *Pressure) String() string {
 	return fmt.Sprintf("%s %d/%d", p.Time.Format(util.TIME_FMT), p.Sys, p.Dia)
 }
@@ -158,7 +225,11 @@ func (i *Influx) sendWeight(name string, w float64, fat float64) error {
 	return i.api.Send(DB, q)
 }
 
+// influxEscaper escapes string literal of InfluxQL query
+var influxEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
 func (i *Influx) getPressure(name string, limit int) ([]Pressure, error) {
+	name = influxEscaper.Replace(name)
 	q := fmt.Sprintf("select time, sys, dia from pressure where \"name\"='%s' and time > now() - %dd limit %d", name, i.days, limit)
 
 	r, err := i.api.GetSingleSeries(DB, q)
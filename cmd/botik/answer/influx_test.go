@@ -23,6 +23,7 @@ func (i *MockInflux) GetData(db, q string) (api.InfluxAnswer, error) {
 }
 
 func (i *MockInflux) GetSingleSeries(db string, q string) ([]map[string]interface{}, error) {
+	i.result = q
 	return nil, nil
 }
 
@@ -99,3 +100,58 @@ func TestInfluxSendBPtNote(t *testing.T) {
 		t.Errorf("bad send %s", mock.result)
 	}
 }
+
+func TestBpOther(t *testing.T) {
+	i := NewInflux("http://localhost:8086")
+
+	assert.Equal(t, BP, i.Check("user", "давление", "").Cmd)
+	assert.Equal(t, BP, i.Check("user", "давление 120 80", "").Cmd)
+
+	q := i.Check("user", "давление мама", "")
+	assert.Equal(t, BP_OTHER, q.Cmd)
+	assert.Equal(t, "мама", q.Payload)
+}
+
+func TestBpOtherUsers(t *testing.T) {
+	i := &Influx{api: &MockInflux{}}
+	i.SetUsers("User", "Мама")
+
+	assert.Contains(t, i.Process(i.Check("user", "давление мама", "")).Msg, "Давление за последние")
+	assert.Equal(t, "нет такого пользователя: сосед", i.Process(i.Check("user", "давление сосед", "")).Msg)
+}
+
+func TestInfluxEscape(t *testing.T) {
+	m := &MockInflux{}
+	i := &Influx{api: m, days: 10}
+
+	_, err := i.getPressure(`x\' or 1=1 --`, 5)
+	assert.NoError(t, err)
+	assert.Contains(t, m.result, `"name"='x\\\' or 1=1 --'`)
+}
+
+func TestInfluxSlots(t *testing.T) {
+	m := &MockInflux{}
+	i := &Influx{api: m, days: 10}
+	i.SetUsers("user", "мама")
+
+	tests := []struct {
+		msg    string
+		result string
+	}{
+		{"давление после зарядки 130 85", "pressure,name=user sys=130,dia=85,note=\"после зарядки\" "},
+		{"давление 120 на 80", "pressure,name=user sys=120,dia=80 "},
+		{"вес сегодня 90,5", "weight,name=user weight=90.500000 "},
+		{"давление для мамы", `"name"='мама'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			m.result = ""
+			i.Process(i.Check("user", tt.msg, ""))
+			assert.Contains(t, m.result, tt.result)
+		})
+	}
+
+	assert.Contains(t, i.Process(i.Check("user", "давление 120", "")).Msg, "использование")
+	assert.Contains(t, i.Process(i.Check("user", "вес много", "")).Msg, "использование")
+}
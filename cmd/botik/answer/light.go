@@ -28,102 +28,68 @@ func NewLight(logger *slog.Logger, host string) *Light {
 	}
 }
 
-func (l *Light) Check(user string, msg string, repl string) (q *Q) {
-	m := strings.ToLower(msg)
-	q = &Q{Msg: msg, User: user}
-
-	words := q.Words()
-
-	if HasPrefix(m, "light") {
-		if len(words) == 1 {
-			q.Matched = true
-			q.Prefix = ""
-			q.Cmd = STATUS
-			return
-		}
-	}
-
-	if s := LongestPrefix(m, "включи", "включить"); s != "" {
-		q.Matched = true
-		q.Prefix = s
-		q.Cmd = ON
-		if IndexOf(words, "весь", "везде", "улице", "уличный", "снаружи") > -1 {
-			q.Payload = "lights_out"
-		}
-		return
-	}
+// rooms are light items, words are in any case form
+var rooms = Dict{
+	"max":            {"макс", "максина"},
+	"kitchen":        {"кухня"},
+	"light_room":     {"комната", "спальня"},
+	"light_corridor": {"коридор", "прихожая"},
+}
 
-	if s := LongestPrefix(m, "выключи", "выключить"); s != "" {
-		q.Matched = true
-		q.Prefix = s
-		q.Cmd = OFF
-		if IndexOf(words, "весь", "везде") > -1 {
-			q.Payload = "lights"
-		}
-		if IndexOf(words, "улице", "уличный", "снаружи") > -1 {
-			q.Payload = "lights_out"
-		}
-		return
-	}
+var lightGrammar = Grammar{
+	{Cmd: STATUS, Phrases: []string{"light"}, Alone: true},
+	{Cmd: ON, Phrases: []string{"включи", "включить"}, Slots: []Slot{
+		{Name: "group", Kind: SlotEntity, Dict: Dict{"lights_out": {"весь", "везде", "улица", "уличный", "снаружи"}}},
+		{Name: "item", Kind: SlotEntity, Dict: rooms},
+	}},
+	{Cmd: OFF, Phrases: []string{"выключи", "выключить"}, Slots: []Slot{
+		{Name: "group", Kind: SlotEntity, Dict: Dict{"lights": {"весь", "везде"}, "lights_out": {"улица", "уличный", "снаружи"}}},
+		{Name: "item", Kind: SlotEntity, Dict: rooms},
+	}},
+	{Cmd: NIGHT, Phrases: []string{"спать", "ночной режим", "ночь"}},
+	{Cmd: DAY, Phrases: []string{"день"}},
+	{Cmd: NOBODY_HOME, Phrases: []string{"жди", "все ушли", "один дома"}},
+	{Cmd: STATUS, Phrases: []string{"свет", "статус"}},
+}
 
-	if s := LongestPrefix(m, "спать", "ночной режим", "ночь"); s != "" {
-		q.Matched = true
-		q.Prefix = s
-		q.Cmd = NIGHT
-		return
-	}
+func (l *Light) Check(user string, msg string, repl string) (q *Q) {
+	q = lightGrammar.Check(user, msg, repl)
+	q.Payload = q.Slots["group"]
 
-	if s := LongestPrefix(m, "день"); s != "" {
-		q.Matched = true
-		q.Prefix = s
-		q.Cmd = DAY
-		return
-	}
+	return q
+}
 
-	if s := LongestPrefix(m, "жди", "все ушли", "один дома"); s != "" {
-		q.Matched = true
-		q.Prefix = s
-		q.Cmd = NOBODY_HOME
-		return
-	}
+func (l *Light) Commands() []Command {
+	where := "<что> | весь свет | на улице"
 
-	if s := LongestPrefix(m, "свет", "статус"); s != "" {
-		q.Matched = true
-		q.Prefix = s
-		q.Cmd = STATUS
-		return
+	return []Command{
+		{Name: "light", Aliases: []string{"свет", "статус"}, Cmd: STATUS, Description: "состояние света", DescriptionEn: "light status"},
+		{Name: "включи", Aliases: []string{"включить"}, Cmd: ON, Args: where, Description: "включить свет", DescriptionEn: "turn light on"},
+		{Name: "выключи", Aliases: []string{"выключить"}, Cmd: OFF, Args: where, Description: "выключить свет", DescriptionEn: "turn light off"},
+		{Name: "спать", Aliases: []string{"ночной режим", "ночь"}, Cmd: NIGHT, Description: "ночной режим", DescriptionEn: "night mode"},
+		{Name: "день", Cmd: DAY, Description: "дневной режим", DescriptionEn: "day mode"},
+		{Name: "жди", Aliases: []string{"все ушли", "один дома"}, Cmd: NOBODY_HOME, Description: "никого нет дома", DescriptionEn: "nobody is home"},
 	}
-
-	q.Matched = false
-	return
 }
 
 func (l *Light) Process(q *Q) *Answer {
-	words := q.Words()
-
 	switch q.Cmd {
 	case ON:
 		if q.Payload != "" {
 			l.logger.Info("lights on for " + q.Payload)
 			err := l.mahno.GroupCommand(q.Payload, ON)
 			if err != nil {
-				return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
+				return ErrorAnswer(err)
 			}
 
 			return TextAnswer("включаю свет")
 		} else {
-			target := getTarget(words)
+			target := q.Slots["item"]
 			if target == "" {
-				return TextAnswer(fmt.Sprintf("не понимаю %s", q.Msg))
+				return askRoom("где?")
 			}
 
-			l.logger.Info("light ON to " + target)
-			err := l.mahno.ItemCommand(target, ON)
-
-			if err != nil {
-				return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
-			}
-			return TextAnswer(fmt.Sprintf("включаю %s", target))
+			return l.itemCommand(target, q.Cmd)
 		}
 
 	case OFF:
@@ -131,23 +97,17 @@ func (l *Light) Process(q *Q) *Answer {
 			l.logger.Info("lights off for " + q.Payload)
 			err := l.mahno.GroupCommand(q.Payload, OFF)
 			if err != nil {
-				return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
+				return ErrorAnswer(err)
 			}
 
 			return TextAnswer("включаю свет")
 		} else {
-			target := getTarget(words)
+			target := q.Slots["item"]
 			if target == "" {
-				return TextAnswer(fmt.Sprintf("не понимаю %s", q.Msg))
+				return askRoom("где?")
 			}
 
-			l.logger.Info("light OFF to " + target)
-			err := l.mahno.ItemCommand(target, OFF)
-
-			if err != nil {
-				return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
-			}
-			return TextAnswer(fmt.Sprintf("выключаю %s", target))
+			return l.itemCommand(target, q.Cmd)
 		}
 
 	case DAY:
@@ -155,7 +115,7 @@ func (l *Light) Process(q *Q) *Answer {
 		err := l.mahno.SetItemState("home_mode", "day")
 
 		if err != nil {
-			return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
+			return ErrorAnswer(err)
 		}
 		return TextAnswer("дневной режим")
 
@@ -164,7 +124,7 @@ func (l *Light) Process(q *Q) *Answer {
 		err := l.mahno.SetItemState("home_mode", "night")
 
 		if err != nil {
-			return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
+			return ErrorAnswer(err)
 		}
 		return TextAnswer("ночной режим")
 
@@ -173,14 +133,14 @@ func (l *Light) Process(q *Q) *Answer {
 		err := l.mahno.SetItemState("home_mode", "nobody_home")
 
 		if err != nil {
-			return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
+			return ErrorAnswer(err)
 		}
 		return TextAnswer("режим отсутствия")
 
 	case STATUS:
 		res, err := l.mahno.AllItems()
 		if err != nil {
-			return TextAnswer(fmt.Sprintf("ошибка: %s", err.Error()))
+			return ErrorAnswer(err)
 		}
 
 		sb := new(strings.Builder)
@@ -198,31 +158,34 @@ func (l *Light) Process(q *Q) *Answer {
 	}
 }
 
-func getTarget(words []string) string {
-	if i := IndexOf(words, "в", "на", "у"); i > -1 {
-		if len(words) <= i+1 {
-			return ""
-		}
-		return getItemName(words[i+1])
+func askRoom(msg string) *Answer {
+	a := AskAnswer(msg, &Question{Step: "room", Expect: InputText})
+	a.Keyboard = ReplyKeyboard("на кухне", "в комнате", "в коридоре", "у макса")
+
+	return a
+}
+
+// Continue gets the room for ON and OFF commands without it, like "на кухне" or "кухня".
+func (l *Light) Continue(s *Session, input string) *Answer {
+	target, _, ok := rooms.Find(Tokenize(input))
+
+	if !ok {
+		return askRoom("не знаю такого места, где?")
 	}
-	return ""
+
+	return l.itemCommand(target, s.Q.Cmd)
 }
 
-func getItemName(s string) string {
-	var TARGET = map[string][]string{
-		"max":            {"максиной", "макса"},
-		"kitchen":        {"кухня", "кухне"},
-		"light_room":     {"комнате", "спальне"},
-		"light_corridor": {"коридоре", "корридоре", "прихожей", "прихожая"},
+func (l *Light) itemCommand(target string, cmd string) *Answer {
+	l.logger.Info("light " + cmd + " to " + target)
+
+	if err := l.mahno.ItemCommand(target, cmd); err != nil {
+		return ErrorAnswer(err)
 	}
 
-	for k, v := range TARGET {
-		for _, v1 := range v {
-			if s == v1 {
-				return k
-			}
-		}
+	if cmd == ON {
+		return TextAnswer(fmt.Sprintf("включаю %s", target))
 	}
 
-	return ""
+	return TextAnswer(fmt.Sprintf("выключаю %s", target))
 }
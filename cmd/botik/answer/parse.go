@@ -0,0 +1,424 @@
+package answer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"botik/internal/util"
+)
+
+// Token is a word of the message.
+type Token struct {
+	// Raw is the word as it is in the message
+	Raw string
+	// Text is lower case word with ё replaced by е
+	Text string
+	// Stem is the word without russian endings, "кухне" and "кухня" are "кухн"
+	Stem string
+}
+
+// Tokenize splits message to words. Punctuation around words is dropped, commas and other separators split words
+// unless they are inside of a number, so "5,25", "18:30" and "1h30m" are one token.
+func Tokenize(s string) []Token {
+	res := make([]Token, 0)
+
+	for _, f := range strings.Fields(s) {
+		for _, w := range splitSeparators(f) {
+			w = strings.TrimFunc(w, func(r rune) bool {
+				return unicode.IsPunct(r) || unicode.IsSymbol(r)
+			})
+
+			if w == "" {
+				continue
+			}
+
+			text := normalize(w)
+			res = append(res, Token{Raw: w, Text: text, Stem: Stem(text)})
+		}
+	}
+
+	return res
+}
+
+func splitSeparators(s string) []string {
+	r := []rune(s)
+	res := make([]string, 0, 1)
+	start := 0
+
+	for i, c := range r {
+		if !strings.ContainsRune(",;!?", c) {
+			continue
+		}
+
+		if i > 0 && i < len(r)-1 && unicode.IsDigit(r[i-1]) && unicode.IsDigit(r[i+1]) {
+			continue
+		}
+
+		res = append(res, string(r[start:i]))
+		start = i + 1
+	}
+
+	return append(res, string(r[start:]))
+}
+
+func normalize(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+}
+
+// Texts returns lower case words of tokens.
+func Texts(tokens []Token) []string {
+	res := make([]string, 0, len(tokens))
+
+	for _, t := range tokens {
+		res = append(res, t.Text)
+	}
+
+	return res
+}
+
+// russian stemmer, it is snowball russian algorithm
+
+const ruVowels = "аеиоуыэюя"
+
+var (
+	perfectiveGerund1 = []string{"вшись", "вши", "в"}
+	perfectiveGerund2 = []string{"ившись", "ывшись", "ивши", "ывши", "ив", "ыв"}
+	adjective         = []string{"ими", "ыми", "его", "ого", "ему", "ому", "ее", "ие", "ые", "ое", "ей", "ий", "ый", "ой",
+		"ем", "им", "ым", "ом", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	participle1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	participle2 = []string{"ивш", "ывш", "ующ"}
+	reflexive   = []string{"ся", "сь"}
+	verb1       = []string{"ете", "йте", "ешь", "нно", "ла", "на", "ли", "ем", "ло", "но", "ет", "ют", "ны", "ть", "й", "л", "н"}
+	verb2       = []string{"ейте", "уйте", "ила", "ыла", "ена", "ите", "или", "ыли", "ило", "ыло", "ено", "ует", "уют", "ены",
+		"ить", "ыть", "ишь", "ей", "уй", "ил", "ыл", "им", "ым", "ен", "ят", "ит", "ыт", "ую", "ю"}
+	noun = []string{"иями", "ями", "ами", "ией", "иям", "ием", "иях", "ев", "ов", "ие", "ье", "еи", "ии", "ей", "ой", "ий",
+		"ям", "ем", "ам", "ом", "ах", "ях", "ию", "ью", "ия", "ья", "а", "е", "и", "й", "о", "у", "ы", "ь", "ю", "я"}
+	superlative  = []string{"ейше", "ейш"}
+	derivational = []string{"ость", "ост"}
+	// the first group suffixes follow these letters
+	group1Precede = "ая"
+)
+
+// Stem removes russian endings of the word, other words are returned as is.
+func Stem(word string) string {
+	w := []rune(normalize(word))
+
+	rv, r2 := regions(w)
+
+	if rv >= len(w) {
+		return string(w)
+	}
+
+	// step 1
+	if n := suffixAfter(w, rv, perfectiveGerund1, perfectiveGerund2); n > 0 {
+		w = w[:len(w)-n]
+	} else {
+		if n := suffix(w, rv, reflexive); n > 0 {
+			w = w[:len(w)-n]
+		}
+
+		if n := adjectival(w, rv); n > 0 {
+			w = w[:len(w)-n]
+		} else if n := suffixAfter(w, rv, verb1, verb2); n > 0 {
+			w = w[:len(w)-n]
+		} else if n := suffix(w, rv, noun); n > 0 {
+			w = w[:len(w)-n]
+		}
+	}
+
+	// step 2
+	if n := suffix(w, rv, []string{"и"}); n > 0 {
+		w = w[:len(w)-n]
+	}
+
+	// step 3
+	if n := suffix(w, r2, derivational); n > 0 {
+		w = w[:len(w)-n]
+	}
+
+	// step 4
+	switch {
+	case suffix(w, rv, []string{"нн"}) > 0:
+		w = w[:len(w)-1]
+	case suffix(w, rv, superlative) > 0:
+		w = w[:len(w)-suffix(w, rv, superlative)]
+
+		if suffix(w, rv, []string{"нн"}) > 0 {
+			w = w[:len(w)-1]
+		}
+	case suffix(w, rv, []string{"ь"}) > 0:
+		w = w[:len(w)-1]
+	}
+
+	return string(w)
+}
+
+// regions returns start of RV, the part after the first vowel, and R2.
+func regions(w []rune) (int, int) {
+	isVowel := func(r rune) bool { return strings.ContainsRune(ruVowels, r) }
+
+	rv, r1, r2 := len(w), len(w), len(w)
+
+	for i, r := range w {
+		if isVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+
+	// R1 is after the first non-vowel following a vowel, R2 is R1 of R1
+	for i := 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			r1 = i + 1
+			break
+		}
+	}
+
+	for i := r1 + 1; i < len(w); i++ {
+		if !isVowel(w[i]) && isVowel(w[i-1]) {
+			r2 = i + 1
+			break
+		}
+	}
+
+	return rv, r2
+}
+
+// suffix returns length of the longest of suffixes the word ends with in region starting at from.
+func suffix(w []rune, from int, suffixes []string) int {
+	best := 0
+
+	for _, s := range suffixes {
+		n := utf8.RuneCountInString(s)
+
+		if n > best && len(w)-n >= from && string(w[len(w)-n:]) == s {
+			best = n
+		}
+	}
+
+	return best
+}
+
+// suffixAfter is suffix for groups where the first group suffixes must follow а or я.
+func suffixAfter(w []rune, from int, group1, group2 []string) int {
+	best := suffix(w, from, group2)
+
+	if n := suffix(w, from, group1); n > best && len(w)-n-1 >= from && strings.ContainsRune(group1Precede, w[len(w)-n-1]) {
+		best = n
+	}
+
+	return best
+}
+
+func adjectival(w []rune, from int) int {
+	n := suffix(w, from, adjective)
+
+	if n == 0 {
+		return 0
+	}
+
+	return n + suffixAfter(w[:len(w)-n], from, participle1, participle2)
+}
+
+// Distance is Damerau-Levenshtein distance between words, a swap of two letters is one edit.
+func Distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(s)][len(t)]
+}
+
+// typos is the number of typos allowed in the word, short words must be exact.
+func typos(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n >= 10:
+		return 2
+	case n >= 6:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// match kinds, better match has bigger value
+const (
+	matchNone = iota
+	matchFuzzy
+	matchStem
+	matchExact
+)
+
+// matchWord compares token with dictionary word, it returns match kind and number of typos.
+func matchWord(t Token, word Token) (int, int) {
+	switch {
+	case t.Text == word.Text:
+		return matchExact, 0
+	case utf8.RuneCountInString(word.Stem) > 1 && t.Stem == word.Stem:
+		return matchStem, 0
+	}
+
+	// typo in other form of the word, like "корридоре"
+	if n := typos(word.Text); n > 0 {
+		if d := min(Distance(t.Text, word.Text), Distance(t.Stem, word.Stem)); d <= n {
+			return matchFuzzy, d
+		}
+	}
+
+	return matchNone, 0
+}
+
+var numberWords = map[string]int{
+	"один": 1, "одна": 1, "одну": 1, "два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5, "шесть": 6, "семь": 7,
+	"восемь": 8, "девять": 9, "десять": 10, "пятнадцать": 15, "двадцать": 20, "тридцать": 30, "сорок": 40,
+	"пятьдесят": 50,
+}
+
+// ParseNumber parses number with decimal point or comma, like "95,2".
+func ParseNumber(s string) (float64, bool) {
+	if n, ok := numberWords[normalize(s)]; ok {
+		return float64(n), true
+	}
+
+	// ParseFloat takes "nan" and "inf" too
+	if !strings.ContainsAny(s, "0123456789") || strings.ContainsFunc(s, unicode.IsLetter) {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+
+	return f, err == nil
+}
+
+// durationUnit finds time unit by the beginning of the word, so every form of "минута" or "час" is found.
+func durationUnit(s string) (time.Duration, bool) {
+	for _, u := range []struct {
+		prefix string
+		d      time.Duration
+	}{
+		{"полчаса", 30 * time.Minute},
+		{"сек", time.Second},
+		{"мин", time.Minute},
+		{"м", time.Minute},
+		{"час", time.Hour},
+		{"ч", time.Hour},
+		{"сут", 24 * time.Hour},
+		{"дн", 24 * time.Hour},
+		{"ден", 24 * time.Hour},
+		{"д", 24 * time.Hour},
+		{"недел", 7 * 24 * time.Hour},
+	} {
+		// one letter units are only short forms, like "2ч"
+		if utf8.RuneCountInString(u.prefix) == 1 {
+			if s == u.prefix {
+				return u.d, true
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(s, u.prefix) {
+			return u.d, true
+		}
+	}
+
+	return 0, false
+}
+
+// ParseDuration finds duration in tokens, like "2h", "2ч", "10 минут", "полчаса" or "через час".
+// It returns the duration and the range of tokens it takes.
+func ParseDuration(tokens []Token) (time.Duration, int, int, bool) {
+	for i, t := range tokens {
+		if d, err := util.ParseDuration(t.Text); err == nil && d > 0 {
+			return d, i, i + 1, true
+		}
+
+		// "2ч", "30мин"
+		if n := strings.IndexFunc(t.Text, func(r rune) bool { return !unicode.IsDigit(r) }); n > 0 {
+			if u, ok := durationUnit(t.Text[n:]); ok {
+				v, _ := strconv.Atoi(t.Text[:n])
+				return time.Duration(v) * u, i, i + 1, true
+			}
+		}
+
+		if u, ok := durationUnit(t.Text); ok && utf8.RuneCountInString(t.Text) > 1 {
+			if i > 0 {
+				if v, ok := ParseNumber(tokens[i-1].Text); ok {
+					return time.Duration(v * float64(u)), i - 1, i + 1, true
+				}
+			}
+
+			return u, i, i + 1, true
+		}
+	}
+
+	return 0, 0, 0, false
+}
+
+// FormatDuration formats duration as util.ParseDuration takes it, like "1d", "2h" or "1h30m".
+func FormatDuration(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+
+	s := d.String()
+
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+
+	return s
+}
+
+// ParseClock parses time of day, like "18:30" or "9.05".
+func ParseClock(s string) (int, int, bool) {
+	h, m, ok := strings.Cut(s, ":")
+
+	if !ok {
+		h, m, ok = strings.Cut(s, ".")
+	}
+
+	if !ok || len(m) != 2 {
+		return 0, 0, false
+	}
+
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+
+	if err1 != nil || err2 != nil || hh < 0 || hh > 23 || mm < 0 || mm > 59 {
+		return 0, 0, false
+	}
+
+	return hh, mm, true
+}
@@ -0,0 +1,128 @@
+package answer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize(` Свет,пожалуйста!  "5,25" 18:30 (Кухня) 1h30m abc-def ёлка... `)
+
+	assert.Equal(t, []string{"свет", "пожалуйста", "5,25", "18:30", "кухня", "1h30m", "abc-def", "елка"}, Texts(tokens))
+	assert.Equal(t, "Кухня", tokens[4].Raw)
+	assert.Equal(t, "кухн", tokens[4].Stem)
+
+	assert.Empty(t, Tokenize(" !? ... "))
+}
+
+func TestStem(t *testing.T) {
+	tests := map[string][]string{
+		"кухн":    {"кухня", "кухне", "кухню", "кухней"},
+		"комнат":  {"комната", "комнате", "комнаты"},
+		"коридор": {"коридор", "коридоре"},
+		"прихож":  {"прихожая", "прихожей"},
+		"включ":   {"включи", "включить", "включите"},
+		"выключ":  {"выключи", "выключить"},
+		"давлен":  {"давление", "давлении"},
+		"истор":   {"история", "истории"},
+		"mute":    {"mute"},
+	}
+
+	for stem, words := range tests {
+		for _, w := range words {
+			assert.Equal(t, stem, Stem(w), w)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance("свет", "свет"))
+	assert.Equal(t, 1, Distance("вкючи", "включи"))
+	assert.Equal(t, 1, Distance("корридор", "коридор"))
+	// swap is one typo
+	assert.Equal(t, 1, Distance("вклюич", "включи"))
+	assert.Equal(t, 3, Distance("", "abc"))
+}
+
+func TestMatchWord(t *testing.T) {
+	m, _ := matchWord(wordToken("кухне"), wordToken("кухня"))
+	assert.Equal(t, matchStem, m)
+
+	m, d := matchWord(wordToken("вкючи"), wordToken("включи"))
+	assert.Equal(t, matchFuzzy, m)
+	assert.Equal(t, 1, d)
+
+	// short words must be exact
+	m, _ = matchWord(wordToken("выкл"), wordToken("вкл"))
+	assert.Equal(t, matchNone, m)
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want time.Duration
+	}{
+		{"2h", 2 * time.Hour},
+		{"1h30m", 90 * time.Minute},
+		{"1d", 24 * time.Hour},
+		{"на 2 часа", 2 * time.Hour},
+		{"через 10 минут", 10 * time.Minute},
+		{"2ч", 2 * time.Hour},
+		{"30мин", 30 * time.Minute},
+		{"полчаса", 30 * time.Minute},
+		{"на час", time.Hour},
+		{"одну минуту", time.Minute},
+		{"на 3 дня", 72 * time.Hour},
+		{"на неделю", 7 * 24 * time.Hour},
+		{"1,5 часа", 90 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		d, _, _, ok := ParseDuration(Tokenize(tt.msg))
+		assert.True(t, ok, tt.msg)
+		assert.Equal(t, tt.want, d, tt.msg)
+	}
+
+	for _, msg := range []string{"", "abc", "5", "на кухне"} {
+		_, _, _, ok := ParseDuration(Tokenize(msg))
+		assert.False(t, ok, msg)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	for s, want := range map[string]float64{"95,2": 95.2, "120": 120, "-5": -5, "две": 2, "Десять": 10} {
+		n, ok := ParseNumber(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, want, n, s)
+	}
+
+	for _, s := range []string{"nan", "inf", "1e5", "abc", "", "5,2,1"} {
+		_, ok := ParseNumber(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "2h", FormatDuration(2*time.Hour))
+	assert.Equal(t, "1h30m", FormatDuration(90*time.Minute))
+	assert.Equal(t, "10m", FormatDuration(10*time.Minute))
+	assert.Equal(t, "3d", FormatDuration(72*time.Hour))
+	assert.Equal(t, "45s", FormatDuration(45*time.Second))
+}
+
+func TestParseClock(t *testing.T) {
+	h, m, ok := ParseClock("18:30")
+	assert.True(t, ok)
+	assert.Equal(t, []int{18, 30}, []int{h, m})
+
+	h, m, ok = ParseClock("9.05")
+	assert.True(t, ok)
+	assert.Equal(t, []int{9, 5}, []int{h, m})
+
+	for _, s := range []string{"25:00", "5,25", "12:5", "abc"} {
+		_, _, ok := ParseClock(s)
+		assert.False(t, ok, s)
+	}
+}
@@ -0,0 +1,243 @@
+package answer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"botik/cmd/botik/audit"
+	"botik/internal/util"
+)
+
+// expected input of a question
+const (
+	InputText     = "text"
+	InputNumber   = "number"
+	InputDuration = "duration"
+	InputChoice   = "choice"
+)
+
+const (
+	DefaultQuestionTimeout = 2 * time.Minute
+	// maxInvalidInputs is the number of wrong inputs after which conversation is dropped
+	maxInvalidInputs = 3
+)
+
+var cancelWords = []string{"cancel", "отмена"}
+
+// Question is a follow-up question of answerer, the next message of the user in the chat is its answer.
+type Question struct {
+	// Step tells the answerer which question is answered
+	Step    string
+	Expect  string
+	Choices []string
+	Timeout time.Duration
+}
+
+// Session is a conversation of the user with answerer in the chat.
+type Session struct {
+	Answerer string
+	User     string
+	Chat     int64
+	// Q is the command which started the conversation
+	Q *Q
+	// Data is kept between steps
+	Data     map[string]string
+	Step     string
+	Question *Question
+	Expires  time.Time
+	invalid  int
+}
+
+// Conversational answerer can ask questions, Process or Continue return AskAnswer for that.
+// Continue gets the answer to the question, it is checked against Question.Expect.
+type Conversational interface {
+	Continue(s *Session, input string) *Answer
+}
+
+type sessionKey struct {
+	user string
+	chat int64
+}
+
+// AskAnswer asks the user a question, the answer goes to Continue of the answerer.
+func AskAnswer(msg string, q *Question) *Answer {
+	return &Answer{Msg: msg, Ask: q}
+}
+
+// validate checks input against expected type and returns a hint if it is invalid.
+func (q *Question) validate(input string) (string, bool) {
+	switch q.Expect {
+	case InputNumber:
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(input, ",", "."), 64); err != nil {
+			return "нужно число", false
+		}
+	case InputDuration:
+		if _, err := util.ParseDuration(input); err != nil {
+			return "нужна длительность, например 30m, 2h, 1d", false
+		}
+	case InputChoice:
+		for _, c := range q.Choices {
+			if strings.EqualFold(c, input) {
+				return "", true
+			}
+		}
+
+		return "выберите одно из: " + strings.Join(q.Choices, ", "), false
+	}
+
+	return "", true
+}
+
+// Session returns active conversation of the user in the chat.
+func (am *AnswerManager) Session(user string, chat int64) *Session {
+	am.smx.Lock()
+	defer am.smx.Unlock()
+
+	key := sessionKey{user: strings.ToLower(user), chat: chat}
+	s := am.sessions[key]
+
+	if s != nil && !am.now().Before(s.Expires) {
+		delete(am.sessions, key)
+		return nil
+	}
+
+	return s
+}
+
+func (am *AnswerManager) endSession(s *Session) {
+	am.smx.Lock()
+	defer am.smx.Unlock()
+
+	delete(am.sessions, sessionKey{user: strings.ToLower(s.User), chat: s.Chat})
+}
+
+// ask starts or continues conversation if the answer has a question.
+func (am *AnswerManager) ask(s *Session, a *Answer) {
+	if a == nil || a.Ask == nil {
+		am.endSession(s)
+		return
+	}
+
+	timeout := a.Ask.Timeout
+	if timeout <= 0 {
+		timeout = DefaultQuestionTimeout
+	}
+
+	s.Step, s.Question, s.Expires, s.invalid = a.Ask.Step, a.Ask, am.now().Add(timeout), 0
+
+	if a.Keyboard == nil && a.Ask.Expect == InputChoice {
+		a.Keyboard = ReplyKeyboard(a.Ask.Choices...)
+	}
+
+	am.smx.Lock()
+	defer am.smx.Unlock()
+
+	am.sessions[sessionKey{user: strings.ToLower(s.User), chat: s.Chat}] = s
+}
+
+// converse routes message of the user to the answerer which asked a question. It returns nil if there is
+// no conversation or message is a new /command, which ends the conversation.
+func (am *AnswerManager) converse(req *Request) *Answer {
+	msg := strings.TrimSpace(req.Msg)
+	cancel := util.IsInArray(strings.ToLower(strings.TrimLeft(msg, "/")), cancelWords...)
+
+	s := am.Session(req.User, req.Chat)
+
+	if s == nil {
+		if cancel {
+			return TextAnswer("нечего отменять")
+		}
+
+		return nil
+	}
+
+	if cancel {
+		am.endSession(s)
+		return TextAnswer("отменено")
+	}
+
+	if strings.HasPrefix(msg, "/") {
+		am.endSession(s)
+		return nil
+	}
+
+	// a command without slash, like "алерты", is not an answer to the question either
+	if best, ambiguous := am.match(req); best != nil || len(ambiguous) > 0 {
+		am.endSession(s)
+		return nil
+	}
+
+	e := &audit.Entry{Source: audit.SourceTelegram, User: req.User, Chat: req.Chat, Msg: req.Msg,
+		Answerer: s.Answerer, Cmd: s.Q.Cmd, Payload: msg}
+
+	if hint, ok := s.Question.validate(msg); !ok {
+		if am.invalid(s, e) {
+			return TextAnswer(hint + ", отменено")
+		}
+
+		return TextAnswer(fmt.Sprintf("%s, /cancel для отмены", hint))
+	}
+
+	c, ok := am.answerers[s.Answerer].(Conversational)
+
+	if !ok {
+		am.endSession(s)
+		return nil
+	}
+
+	a := c.Continue(s, msg)
+
+	// the same question again means the answerer has not accepted the input, it keeps the timeout
+	if a != nil && a.Ask != nil && a.Ask.Step == s.Step {
+		if am.invalid(s, e) {
+			return TextAnswer("не понял ответ, отменено")
+		}
+
+		return a
+	}
+
+	if a != nil && a.Err != nil {
+		e.Outcome = audit.OutcomeError
+		e.Error = a.Err.Error()
+	}
+
+	am.auditor.Record(e)
+	am.ask(s, a)
+	bindButtons(s.Answerer, s.Q.Cmd, a)
+
+	return a
+}
+
+// invalid counts wrong input of the user, it ends conversation and returns true after maxInvalidInputs.
+func (am *AnswerManager) invalid(s *Session, e *audit.Entry) bool {
+	am.smx.Lock()
+	s.invalid++
+	invalid := s.invalid
+	am.smx.Unlock()
+
+	if invalid < maxInvalidInputs {
+		return false
+	}
+
+	am.endSession(s)
+	e.Outcome = audit.OutcomeError
+	e.Error = "invalid input"
+	am.auditor.Record(e)
+
+	return true
+}
+
+// startSession keeps conversation if the answerer asked a question.
+func (am *AnswerManager) startSession(req *Request, name string, q *Q, a *Answer) {
+	if a == nil || a.Ask == nil {
+		return
+	}
+
+	if _, ok := am.answerers[name].(Conversational); !ok {
+		return
+	}
+
+	am.ask(&Session{Answerer: name, User: req.User, Chat: req.Chat, Q: q, Data: make(map[string]string)}, a)
+}
@@ -0,0 +1,148 @@
+package answer
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"botik/cmd/botik/audit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timerAnswerer asks for duration and then for a name.
+type timerAnswerer struct{}
+
+func (t *timerAnswerer) Check(user string, msg string, repl string) *Q {
+	return &Q{Msg: msg, User: user, Matched: strings.HasPrefix(msg, "timer"), Prefix: "timer", Cmd: "timer"}
+}
+
+func (t *timerAnswerer) Process(q *Q) *Answer {
+	return AskAnswer("на сколько?", &Question{Step: "duration", Expect: InputDuration})
+}
+
+func (t *timerAnswerer) Continue(s *Session, input string) *Answer {
+	switch s.Step {
+	case "duration":
+		s.Data["duration"] = input
+		return AskAnswer("какой?", &Question{Step: "kind", Expect: InputChoice, Choices: []string{"чайник", "духовка"}, Timeout: time.Minute})
+	default:
+		return TextAnswer("таймер " + input + " на " + s.Data["duration"])
+	}
+}
+
+func TestSession(t *testing.T) {
+	log := audit.NewMemoryLog()
+
+	am := New()
+	am.SetAuditor(audit.New(slog.Default(), log, 0))
+	require.NoError(t, am.RegisterAnswer("timer", &timerAnswerer{}, PriorityNormal))
+	require.NoError(t, am.RegisterAnswer("light", &testAnswerer{name: "light"}, PriorityNormal))
+
+	now := time.Now()
+	am.now = func() time.Time { return now }
+
+	check := func(user string, chat int64, msg string) string {
+		return am.CheckAnswer(&Request{User: user, Chat: chat, Msg: msg}).Msg
+	}
+
+	assert.Equal(t, "нечего отменять", check("user", 1, "/cancel"))
+
+	assert.Equal(t, "на сколько?", check("user", 1, "timer"))
+	require.NotNil(t, am.Session("user", 1))
+	assert.Nil(t, am.Session("user", 2))
+	assert.Nil(t, am.Session("other", 1))
+
+	// other user and other chat are not in the conversation
+	assert.Equal(t, "ok STATUS", check("other", 1, "light"))
+	assert.Equal(t, "ok STATUS", check("user", 2, "light"))
+
+	assert.Contains(t, check("user", 1, "скоро"), "нужна длительность")
+	ans := am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "10m"})
+	assert.Equal(t, "какой?", ans.Msg)
+	// choices are shown as keyboard
+	assert.Equal(t, ReplyKeyboard("чайник", "духовка"), ans.Keyboard)
+	assert.Equal(t, "kind", am.Session("user", 1).Step)
+	assert.Contains(t, check("user", 1, "утюг"), "выберите одно из: чайник, духовка")
+	assert.Equal(t, "таймер чайник на 10m", check("user", 1, "чайник"))
+	assert.Nil(t, am.Session("user", 1))
+
+	// cancel
+	check("user", 1, "timer")
+	assert.Equal(t, "отменено", check("user", 1, "/cancel"))
+	assert.Nil(t, am.Session("user", 1))
+
+	// new command ends conversation
+	check("user", 1, "timer")
+	assert.Equal(t, "ok STATUS", check("user", 1, "/light"))
+	assert.Nil(t, am.Session("user", 1))
+
+	// timeout
+	check("user", 1, "timer")
+	now = now.Add(DefaultQuestionTimeout)
+	assert.Nil(t, am.Session("user", 1))
+	assert.Equal(t, "ok STATUS", check("user", 1, "light"))
+
+	// too many wrong answers
+	check("user", 1, "timer")
+	check("user", 1, "a")
+	check("user", 1, "b")
+	assert.Contains(t, check("user", 1, "c"), "отменено")
+	assert.Nil(t, am.Session("user", 1))
+
+	entries, err := log.Query(&audit.Filter{Answerer: "timer"})
+	require.NoError(t, err)
+	assert.Equal(t, audit.OutcomeError, entries[len(entries)-1].Outcome)
+}
+
+func TestLightAsksRoom(t *testing.T) {
+	am := newTestManager(t)
+
+	ans := am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "включи"})
+	require.NotNil(t, ans.Ask)
+	assert.Equal(t, "где?", ans.Msg)
+
+	ans = am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "на чердаке"})
+	require.NotNil(t, ans.Ask)
+	assert.Contains(t, ans.Msg, "не знаю такого места")
+
+	s := am.Session("user", 1)
+	require.NotNil(t, s)
+	assert.Equal(t, ON, s.Q.Cmd)
+}
+
+func TestSessionOtherCommand(t *testing.T) {
+	am := newTestManager(t)
+
+	for _, msg := range []string{"выкл", "принял", "mute 2h"} {
+		ans := am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "включи"})
+		require.NotNil(t, ans.Ask)
+
+		// command ends the question and goes to its answerer
+		ans = am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: msg})
+		assert.Nil(t, ans.Ask, msg)
+		assert.NotContains(t, ans.Msg, "не знаю такого места", msg)
+		assert.Nil(t, am.Session("user", 1), msg)
+	}
+}
+
+func TestSessionUnknownRoom(t *testing.T) {
+	am := newTestManager(t)
+
+	now := time.Now()
+	am.now = func() time.Time { return now }
+
+	am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "включи"})
+	expires := am.Session("user", 1).Expires
+
+	now = now.Add(time.Minute)
+	assert.Contains(t, am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "на чердаке"}).Msg, "не знаю такого места")
+	// asking again does not prolong the question
+	assert.Equal(t, expires, am.Session("user", 1).Expires)
+
+	am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "в подвале"})
+	assert.Contains(t, am.CheckAnswer(&Request{User: "user", Chat: 1, Msg: "на крыше"}).Msg, "отменено")
+	assert.Nil(t, am.Session("user", 1))
+}
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"botik/internal/jsonfile"
+)
+
+const (
+	SourceTelegram = "telegram"
+	SourceCallback = "callback"
+	SourceHttp     = "http"
+
+	OutcomeOk      = "ok"
+	OutcomeDenied  = "denied"
+	OutcomeError   = "error"
+	OutcomeUnknown = "unknown"
+	// OutcomeAmbiguous is a message matched by several commands
+	OutcomeAmbiguous = "ambiguous"
+)
+
+// Entry is a record of command or action done through the bot.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	User     string    `json:"user,omitempty"`
+	Chat     int64     `json:"chat,omitempty"`
+	Msg      string    `json:"msg,omitempty"`
+	Answerer string    `json:"answerer,omitempty"`
+	Cmd      string    `json:"cmd,omitempty"`
+	Payload  string    `json:"payload,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (e *Entry) String() string {
+	cmd := strings.Join(strings.Fields(e.Answerer+" "+e.Cmd+" "+e.Payload), " ")
+
+	if cmd == "" {
+		cmd = fmt.Sprintf("%q", e.Msg)
+	}
+
+	s := fmt.Sprintf("%s %s %s %s", e.Time.Format("02.01 15:04"), e.Source, e.User, cmd)
+
+	if e.Outcome != OutcomeOk {
+		s += " - " + e.Outcome
+	}
+
+	if e.Error != "" {
+		s += ": " + e.Error
+	}
+
+	return s
+}
+
+// Filter selects entries, empty fields match everything.
+type Filter struct {
+	Since    time.Time
+	User     string
+	Answerer string
+}
+
+func (f *Filter) Matches(e *Entry) bool {
+	return !e.Time.Before(f.Since) &&
+		(f.User == "" || strings.EqualFold(f.User, e.User)) &&
+		(f.Answerer == "" || strings.EqualFold(f.Answerer, e.Answerer))
+}
+
+// Log is an append-only audit log.
+type Log interface {
+	Append(e *Entry) error
+	// Query returns matching entries, oldest first
+	Query(f *Filter) ([]*Entry, error)
+	// Prune removes entries older than the time
+	Prune(before time.Time) error
+}
+
+// Auditor records entries to log and prunes entries older than retention.
+type Auditor struct {
+	logger    *slog.Logger
+	log       Log
+	retention time.Duration
+	pruned    time.Time
+	now       func() time.Time
+	mx        sync.Mutex
+}
+
+func New(logger *slog.Logger, log Log, retention time.Duration) *Auditor {
+	return &Auditor{
+		logger:    logger.With("logger", "audit"),
+		log:       log,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+func (a *Auditor) Record(e *Entry) {
+	if a == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = a.now()
+	}
+
+	if e.Outcome == "" {
+		e.Outcome = OutcomeOk
+	}
+
+	a.logger.Info("audit", "source", e.Source, "user", e.User, "chat", e.Chat, "answerer", e.Answerer,
+		"cmd", e.Cmd, "payload", e.Payload, "outcome", e.Outcome, "error", e.Error)
+
+	if err := a.log.Append(e); err != nil {
+		a.logger.Error("can't write audit log", "error", err)
+	}
+
+	a.prune()
+}
+
+func (a *Auditor) Query(f *Filter) ([]*Entry, error) {
+	return a.log.Query(f)
+}
+
+func (a *Auditor) prune() {
+	if a.retention <= 0 {
+		return
+	}
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	now := a.now()
+
+	if now.Sub(a.pruned) < time.Hour {
+		return
+	}
+
+	a.pruned = now
+
+	if err := a.log.Prune(now.Add(-a.retention)); err != nil {
+		a.logger.Error("can't prune audit log", "error", err)
+	}
+}
+
+type MemoryLog struct {
+	entries []*Entry
+	mx      sync.RWMutex
+}
+
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{}
+}
+
+func (m *MemoryLog) Append(e *Entry) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.entries = append(m.entries, e)
+
+	return nil
+}
+
+func (m *MemoryLog) Query(f *Filter) ([]*Entry, error) {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	res := make([]*Entry, 0)
+
+	for _, e := range m.entries {
+		if f.Matches(e) {
+			res = append(res, e)
+		}
+	}
+
+	return res, nil
+}
+
+func (m *MemoryLog) Prune(before time.Time) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	i := sort.Search(len(m.entries), func(i int) bool {
+		return !m.entries[i].Time.Before(before)
+	})
+
+	m.entries = m.entries[i:]
+
+	return nil
+}
+
+// FileLog keeps entries in a file, one json per line.
+type FileLog struct {
+	log *jsonfile.Log[*Entry]
+}
+
+func NewFileLog(path string) *FileLog {
+	return &FileLog{log: jsonfile.NewLog[*Entry](path, 0o600)}
+}
+
+func (f *FileLog) Append(e *Entry) error {
+	return f.log.Append(e)
+}
+
+func (f *FileLog) Query(flt *Filter) ([]*Entry, error) {
+	res := make([]*Entry, 0)
+
+	err := f.log.Read(func(e *Entry) {
+		if flt.Matches(e) {
+			res = append(res, e)
+		}
+	})
+
+	return res, err
+}
+
+func (f *FileLog) Prune(before time.Time) error {
+	return f.log.Keep(func(e *Entry) bool {
+		return !e.Time.Before(before)
+	})
+}
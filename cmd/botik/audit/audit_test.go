@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLog(t *testing.T) {
+	l := NewFileLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	now := time.Now().Truncate(time.Second)
+
+	entries, err := l.Query(&Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	require.NoError(t, l.Append(&Entry{Time: now.Add(-time.Hour * 2), User: "User1", Answerer: "light", Cmd: "ON", Outcome: OutcomeOk}))
+	require.NoError(t, l.Append(&Entry{Time: now.Add(-time.Hour), User: "User2", Answerer: "alerts", Cmd: "mute", Outcome: OutcomeDenied}))
+	require.NoError(t, l.Append(&Entry{Time: now, User: "User1", Answerer: "alerts", Cmd: "ack", Outcome: OutcomeOk}))
+
+	entries, err = l.Query(&Filter{User: "user1"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "ON", entries[0].Cmd)
+
+	entries, err = l.Query(&Filter{Since: now.Add(-time.Minute * 90), Answerer: "alerts"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	require.NoError(t, l.Prune(now.Add(-time.Minute*90)))
+
+	entries, err = l.Query(&Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "mute", entries[0].Cmd)
+	assert.True(t, entries[1].Time.Equal(now))
+}
+
+func TestAuditor(t *testing.T) {
+	l := NewMemoryLog()
+	a := New(slog.Default(), l, time.Hour)
+
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	a.Record(&Entry{Source: SourceTelegram, User: "user", Answerer: "light", Cmd: "ON"})
+	a.Record(&Entry{Source: SourceHttp, User: "127.0.0.1", Answerer: "api", Cmd: "send", Outcome: OutcomeError, Error: errors.New("bot is not connected").Error()})
+
+	entries, err := a.Query(&Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, OutcomeOk, entries[0].Outcome)
+	assert.Equal(t, now, entries[0].Time)
+	assert.Contains(t, entries[1].String(), "error: bot is not connected")
+
+	now = now.Add(time.Hour * 2)
+	a.Record(&Entry{Source: SourceCallback, User: "user", Answerer: "alerts", Cmd: "mute"})
+
+	entries, err = a.Query(&Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "mute", entries[0].Cmd)
+
+	var nilAuditor *Auditor
+	nilAuditor.Record(&Entry{})
+}
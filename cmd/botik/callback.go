@@ -0,0 +1,185 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"botik/cmd/botik/alert"
+	"botik/cmd/botik/answer"
+	"botik/cmd/botik/audit"
+	"botik/cmd/botik/outbox"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (app *App) processCallback(cb *tg.CallbackQuery) {
+	logger := app.logger.With(slog.String("from", cb.From.UserName), slog.Int64("id", cb.From.ID))
+
+	user := app.getUser(cb.From.ID)
+
+	if user == "" {
+		logger.Info("unknown user, callback: " + cb.Data)
+		app.answerCallback(cb.ID, "с незнакомыми не разговариваю")
+		return
+	}
+
+	logger.Info("callback: " + cb.Data)
+
+	if app.answerButton(cb, user) {
+		return
+	}
+
+	act, ok := alert.ParseAction(cb.Data)
+
+	if !ok {
+		app.answerCallback(cb.ID, "unknown action")
+		return
+	}
+
+	e := &audit.Entry{Source: audit.SourceCallback, User: user, Msg: cb.Data, Answerer: "alerts", Cmd: act.Cmd, Payload: strings.TrimSpace(act.ID + " " + act.Arg)}
+
+	if cb.Message != nil {
+		e.Chat = cb.Message.Chat.ID
+	}
+
+	if as := app.groupAnswerers(e.Chat); len(as) > 0 && !slices.Contains(as, "alerts") {
+		logger.Warn("alerts are not allowed in the chat")
+		e.Outcome = audit.OutcomeDenied
+		app.auditor.Record(e)
+		app.answerCallback(cb.ID, "недоступно в этом чате")
+		return
+	}
+
+	if cmd := actionCmd(act); !app.ans.Allowed(user, "alerts", cmd) {
+		logger.Warn("access denied", "cmd", cmd)
+		e.Outcome = audit.OutcomeDenied
+		app.auditor.Record(e)
+		app.answerCallback(cb.ID, "нет прав")
+		return
+	}
+
+	n, status, err := app.am.Action(act, user)
+
+	if err != nil {
+		logger.Error("action error", slog.Any("error", err))
+		e.Outcome, e.Error = audit.OutcomeError, err.Error()
+		app.auditor.Record(e)
+		app.answerCallback(cb.ID, err.Error())
+		return
+	}
+
+	app.auditor.Record(e)
+
+	app.answerCallback(cb.ID, status)
+
+	if cb.Message == nil {
+		return
+	}
+
+	if err := app.editTg(cb.Message.Chat.ID, cb.Message.MessageID, n.Text, "HTML", alertKeyboard(n)); err != nil {
+		logger.Error("can't edit message", slog.Any("error", err))
+	}
+}
+
+// answerButton passes press of answerer button to the answerer and sends its answer, it returns false
+// if the button is not of an answerer.
+func (app *App) answerButton(cb *tg.CallbackQuery, user string) bool {
+	var chatID int64
+	var msgID int
+
+	if cb.Message != nil {
+		chatID, msgID = cb.Message.Chat.ID, cb.Message.MessageID
+	}
+
+	ans, ok := app.ans.Callback(&answer.Request{User: user, Chat: chatID, Msg: cb.Data,
+		Answerers: app.groupAnswerers(chatID), Lang: cb.From.LanguageCode}, msgID)
+
+	if !ok {
+		return false
+	}
+
+	if ans == nil {
+		app.answerCallback(cb.ID, "")
+		return true
+	}
+
+	// plain text is shown as callback notification, edits, keyboards and files go to the chat
+	plain := ans.Edit == 0 && ans.Keyboard == nil && ans.Photo == "" && len(ans.Photos) == 0 && ans.Document == nil
+
+	if plain || cb.Message == nil {
+		app.answerCallback(cb.ID, ans.Msg)
+		return true
+	}
+
+	app.answerCallback(cb.ID, "")
+
+	for _, msg := range answerMessages(chatID, ans, 0) {
+		if _, err := app.send(chatID, msg, outbox.PriorityHigh); err != nil {
+			app.logger.Error("can't send message", slog.Any("error", err))
+			return true
+		}
+	}
+
+	return true
+}
+
+// actionCmd returns alerts answerer command that needs the same permission as action.
+func actionCmd(act *alert.Action) string {
+	switch act.Cmd {
+	case "details", "summary":
+		return "alerts"
+	default:
+		return act.Cmd
+	}
+}
+
+func (app *App) answerCallback(id string, text string) {
+	if _, err := app.bot.Request(tg.NewCallback(id, text)); err != nil {
+		app.logger.Error("can't answer callback", slog.Any("error", err))
+	}
+}
+
+// alertKeyboard returns inline keyboard for firing alert notification or nil.
+func alertKeyboard(n *alert.Notification) *tg.InlineKeyboardMarkup {
+	if n.Alert == nil || !alert.IsFiringTemplate(n.Template) {
+		return nil
+	}
+
+	btn := func(text, cmd, arg string) tg.InlineKeyboardButton {
+		return tg.NewInlineKeyboardButtonData(text, (&alert.Action{Cmd: cmd, ID: n.Alert.ID, Arg: arg}).Encode())
+	}
+
+	var rows [][]tg.InlineKeyboardButton
+
+	if n.Silence != nil {
+		rows = append(rows, tg.NewInlineKeyboardRow(btn("Unmute", "unmute", "")))
+	} else {
+		rows = append(rows, tg.NewInlineKeyboardRow(
+			btn("Mute 1h", "mute", "1h"),
+			btn("Mute 1d", "mute", "1d"),
+			btn("Mute forever", "mute", "0"),
+		))
+	}
+
+	row := make([]tg.InlineKeyboardButton, 0, 3)
+
+	if n.AckedBy == "" && n.Alert.Severity() == "critical" {
+		row = append(row, btn("Ack", "ack", ""))
+	}
+
+	if n.Template == "details" {
+		row = append(row, btn("Hide details", "summary", ""))
+	} else {
+		row = append(row, btn("Show details", "details", ""))
+	}
+
+	if n.Url != "" {
+		row = append(row, tg.NewInlineKeyboardButtonURL("Open in "+n.Source, strings.ReplaceAll(n.Url, "/api/v1/alert?", "/vmalert/alert?")))
+	}
+
+	rows = append(rows, row)
+	kb := tg.NewInlineKeyboardMarkup(rows...)
+
+	return &kb
+}
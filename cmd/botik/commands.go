@@ -0,0 +1,33 @@
+package main
+
+import (
+	"botik/cmd/botik/answer"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// menuLanguages are languages of command menu, "" is for users with other languages.
+var menuLanguages = []string{"", "ru", "en"}
+
+// publishCommands sets telegram command menu from commands declared by answerers.
+func (app *App) publishCommands() {
+	cmds := app.ans.MenuCommands()
+
+	for _, lang := range menuLanguages {
+		cfg := tg.NewSetMyCommandsWithScopeAndLanguage(tg.NewBotCommandScopeDefault(), lang, botCommands(cmds, lang)...)
+
+		if _, err := app.bot.Request(cfg); err != nil {
+			app.logger.Error("can't set bot commands", "lang", lang, "error", err)
+		}
+	}
+}
+
+func botCommands(cmds []answer.Command, lang string) []tg.BotCommand {
+	res := make([]tg.BotCommand, 0, len(cmds))
+
+	for _, c := range cmds {
+		res = append(res, tg.BotCommand{Command: c.Name, Description: c.Desc(lang)})
+	}
+
+	return res
+}
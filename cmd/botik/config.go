@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"botik/cmd/botik/route"
+
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
@@ -119,6 +121,23 @@ func (c *AppConfig) MQTTClientID() string {
 	return c.k.String("mqtt.client_id")
 }
 
+// Route returns notification routing tree. Root receivers default to the notify list.
+func (c *AppConfig) Route() (*route.Route, error) {
+	r := new(route.Route)
+
+	if c.k.Exists("route") {
+		if err := c.k.Unmarshal("route", r); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(r.Receivers) == 0 {
+		r.Receivers = c.k.Strings("notify")
+	}
+
+	return r, r.Compile()
+}
+
 func setDefaults(k *koanf.Koanf) {
 	k.Set("listen", ":8088")
 	k.Set("mqtt.server", "192.168.1.1")
@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"strings"
 
+	"botik/cmd/botik/outbox"
+
 	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -55,28 +57,21 @@ func (app *App) ProcessReview(b []byte) error {
 		return nil
 	}
 
-	for _, user := range app.conf.Strings("notify") {
-		id, err := app.IdByName(user)
-
-		if err != nil {
-			app.logger.Error("invalid user "+user, slog.Any("error", err))
-			continue
-		}
-
-		msg := tg.NewMessage(id, msg)
+	labels := map[string]string{
+		"source":   "frigate",
+		"camera":   review.After.Camera,
+		"severity": review.After.Severity,
+		"zones":    strings.Join(review.After.Data.Zones, ","),
+		"objects":  strings.Join(review.After.Data.Objects, ","),
+	}
 
-		if _, err := app.bot.Send(msg); err != nil {
-			app.logger.Error("can't send message", slog.Any("error", err))
-		}
+	for _, id := range app.receivers(labels) {
+		app.sendAsync(id, tg.NewMessage(id, msg), outbox.PriorityNormal)
 		
 		if review.After.ThumbPath != "" {
 			f,_ := strings.CutPrefix(review.After.ThumbPath, "/media/frigate")
 			f = "/home/kott/frigate/storage" + f
-			msg2 := tg.NewPhoto(id, tg.FilePath(f))
-
-			if _, err := app.bot.Send(msg2); err != nil {
-				app.logger.Error("can't send message", slog.Any("error", err))
-			}
+			app.sendAsync(id, tg.NewPhoto(id, tg.FilePath(f)), outbox.PriorityLow)
 		}
 	}
 	
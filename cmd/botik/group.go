@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// mention is @username, telegram user names are letters, digits and underscores
+var mention = regexp.MustCompile(`@\w+`)
+
+// addressed checks if message is meant for the bot. Private messages always are, group messages are
+// if they are commands, mention the bot or reply to bot message. It returns the text without bot mention,
+// so "/light@botik on" becomes "/light on" and "@botik свет" becomes "свет".
+func addressed(message *tg.Message, self tg.User) (string, bool) {
+	text := message.Text
+	mentioned := false
+
+	if self.UserName != "" {
+		stripped := mention.ReplaceAllStringFunc(text, func(m string) string {
+			if strings.EqualFold(m[1:], self.UserName) {
+				return ""
+			}
+
+			return m
+		})
+		mentioned = stripped != text
+		text = strings.TrimSpace(stripped)
+	}
+
+	if message.Chat == nil || message.Chat.IsPrivate() || mentioned {
+		return text, true
+	}
+
+	// command to some other bot in the group, like /start@otherbot, is not for us
+	if first, _, _ := strings.Cut(text, " "); strings.HasPrefix(first, "/") && !strings.Contains(first, "@") {
+		return text, true
+	}
+
+	if r := message.ReplyToMessage; r != nil && r.From != nil && r.From.ID == self.ID {
+		return text, true
+	}
+
+	return text, false
+}
+
+// groupName returns name of the group from config or "" if chat is not a known group.
+func (app *App) groupName(chatID int64) string {
+	for name, id := range app.conf.IntMap("groups") {
+		if int64(id) == chatID {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// groupAnswerers returns answerers allowed in the chat, nil means all of them.
+func (app *App) groupAnswerers(chatID int64) []string {
+	name := app.groupName(chatID)
+
+	if name == "" {
+		return nil
+	}
+
+	return app.conf.Strings("group_answerers." + name)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressed(t *testing.T) {
+	self := tg.User{ID: 42, UserName: "botik", IsBot: true}
+	private := &tg.Chat{ID: 1, Type: "private"}
+	group := &tg.Chat{ID: -100, Type: "supergroup"}
+
+	tests := []struct {
+		name  string
+		msg   *tg.Message
+		text  string
+		addrd bool
+	}{
+		{"private", &tg.Message{Chat: private, Text: "свет"}, "свет", true},
+		{"private command with name", &tg.Message{Chat: private, Text: "/light@botik on"}, "/light on", true},
+		{"group chatter", &tg.Message{Chat: group, Text: "привет всем"}, "привет всем", false},
+		{"group command", &tg.Message{Chat: group, Text: "/light on"}, "/light on", true},
+		{"group command with name", &tg.Message{Chat: group, Text: "/light@botik on"}, "/light on", true},
+		{"group command with other bot", &tg.Message{Chat: group, Text: "/start@otherbot"}, "/start@otherbot", false},
+		{"group mention", &tg.Message{Chat: group, Text: "@Botik свет"}, "свет", true},
+		{"group other mention", &tg.Message{Chat: group, Text: "@botik_fan привет"}, "@botik_fan привет", false},
+		{"group reply to bot", &tg.Message{Chat: group, Text: "ack", ReplyToMessage: &tg.Message{From: &self}}, "ack", true},
+		{"group reply to member", &tg.Message{Chat: group, Text: "ack", ReplyToMessage: &tg.Message{From: &tg.User{ID: 7}}}, "ack", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, ok := addressed(tt.msg, self)
+			assert.Equal(t, tt.text, text)
+			assert.Equal(t, tt.addrd, ok)
+		})
+	}
+}
+
+func TestGroupAnswerers(t *testing.T) {
+	conf := NewAppConfig()
+	conf.k.Set("groups", map[string]any{"family": -100, "work": -200})
+	conf.k.Set("group_answerers", map[string]any{"family": []string{"light", "bp"}})
+
+	app := &App{conf: conf}
+
+	assert.Equal(t, "family", app.groupName(-100))
+	assert.Equal(t, []string{"light", "bp"}, app.groupAnswerers(-100))
+	assert.Empty(t, app.groupAnswerers(-200))
+	assert.Empty(t, app.groupAnswerers(1))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// api scopes, "send:<name>" allows to send to one user, group or channel only
+const (
+	ScopeSend   = "send"
+	ScopeIngest = "ingest"
+	ScopeRead   = "read"
+	ScopeAdmin  = "admin"
+)
+
+const (
+	signatureHeader = "X-Signature"
+	timestampHeader = "X-Timestamp"
+	// signatureMaxAge is how far timestamp of signed request may be from now, older requests are replays
+	signatureMaxAge = time.Minute * 5
+)
+
+// Credential is an api client. It is authenticated by api key, basic auth or HMAC signature of
+// "<timestamp>.<body>", timestamp is unix seconds from X-Timestamp header.
+type Credential struct {
+	Name     string   `koanf:"name"`
+	Key      string   `koanf:"key"`
+	User     string   `koanf:"user"`
+	Password string   `koanf:"password"`
+	Secret   string   `koanf:"hmac_secret"`
+	Scopes   []string `koanf:"scopes"`
+}
+
+type HttpAuthConfig struct {
+	Credentials []*Credential `koanf:"credentials"`
+}
+
+// Allowed checks if credential has the scope, admin has all scopes.
+func (cr *Credential) Allowed(scope string) bool {
+	for _, s := range cr.Scopes {
+		if s == ScopeAdmin || strings.EqualFold(s, scope) {
+			return true
+		}
+
+		// "send" allows "send:<name>"
+		if name, _, ok := strings.Cut(scope, ":"); ok && s == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HttpAuth checks api requests, no checks if there are no credentials.
+type HttpAuth struct {
+	creds []*Credential
+	now   func() time.Time
+}
+
+func NewHttpAuth(conf *HttpAuthConfig) *HttpAuth {
+	if conf == nil || len(conf.Credentials) == 0 {
+		return nil
+	}
+
+	return &HttpAuth{creds: conf.Credentials, now: time.Now}
+}
+
+// Authenticate finds request credential by bearer token, X-Api-Key header, basic auth or body signature.
+func (h *HttpAuth) Authenticate(c *fiber.Ctx) *Credential {
+	auth := c.Get(fiber.HeaderAuthorization)
+
+	key := c.Get("X-Api-Key")
+	if s, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		key = strings.TrimSpace(s)
+	}
+
+	if key != "" {
+		for _, cr := range h.creds {
+			if cr.Key != "" && equal(cr.Key, key) {
+				return cr
+			}
+		}
+
+		return nil
+	}
+
+	if user, password, ok := basicAuth(c); ok {
+		for _, cr := range h.creds {
+			if cr.User != "" && equal(cr.User, user) && equal(cr.Password, password) {
+				return cr
+			}
+		}
+
+		return nil
+	}
+
+	if sig := c.Get(signatureHeader); sig != "" {
+		ts := c.Get(timestampHeader)
+
+		if !h.fresh(ts) {
+			return nil
+		}
+
+		for _, cr := range h.creds {
+			if cr.Secret != "" && validSignature(cr.Secret, ts, c.Body(), sig) {
+				return cr
+			}
+		}
+	}
+
+	return nil
+}
+
+// require lets request go only with credential having the scope, scope func gets scope from request.
+func (h *HttpAuth) require(scope func(c *fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if h == nil {
+			return c.Next()
+		}
+
+		cr := h.Authenticate(c)
+
+		if cr == nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="botik"`)
+			return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+		}
+
+		c.Locals("user", cr.Name)
+
+		if !cr.Allowed(scope(c)) {
+			return c.Status(fiber.StatusForbidden).SendString("forbidden")
+		}
+
+		return c.Next()
+	}
+}
+
+func (h *HttpAuth) scope(s string) fiber.Handler {
+	return h.require(func(_ *fiber.Ctx) string { return s })
+}
+
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	s, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Basic ")
+	if !ok {
+		return "", "", false
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(b), ":")
+}
+
+// fresh checks that signed request timestamp is within signatureMaxAge from now.
+func (h *HttpAuth) fresh(ts string) bool {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	d := h.now().Sub(time.Unix(sec, 0))
+
+	return d < signatureMaxAge && d > -signatureMaxAge
+}
+
+// validSignature checks hex encoded HMAC-SHA256 of "<timestamp>.<body>", with optional "sha256=" prefix.
+func validSignature(secret string, ts string, body []byte, sig string) bool {
+	sig = strings.TrimPrefix(sig, "sha256=")
+
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
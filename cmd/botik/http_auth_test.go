@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialAllowed(t *testing.T) {
+	cr := &Credential{Scopes: []string{"send:User1", ScopeIngest}}
+
+	assert.True(t, cr.Allowed("send:user1"))
+	assert.False(t, cr.Allowed("send:user2"))
+	assert.True(t, cr.Allowed(ScopeIngest))
+	assert.False(t, cr.Allowed(ScopeRead))
+
+	assert.True(t, (&Credential{Scopes: []string{ScopeSend}}).Allowed("send:anyone"))
+	assert.True(t, (&Credential{Scopes: []string{ScopeAdmin}}).Allowed(ScopeRead))
+}
+
+func TestHttpAuth(t *testing.T) {
+	auth := NewHttpAuth(&HttpAuthConfig{Credentials: []*Credential{
+		{Name: "script", Key: "key1", Scopes: []string{"send:user1"}},
+		{Name: "grafana", User: "grafana", Password: "pass", Scopes: []string{ScopeIngest}},
+		{Name: "signed", Secret: "secret", Scopes: []string{ScopeIngest}},
+	}})
+
+	a := fiber.New()
+	a.Post("/send/:name", auth.require(func(c *fiber.Ctx) string { return "send:" + c.Params("name") }), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("user").(string))
+	})
+	a.Post("/grafana", auth.scope(ScopeIngest), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("user").(string))
+	})
+
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+
+	sign := func(ts time.Time) map[string]string {
+		s := strconv.FormatInt(ts.Unix(), 10)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(s + ".body"))
+
+		return map[string]string{"X-Signature": "sha256=" + hex.EncodeToString(mac.Sum(nil)), "X-Timestamp": s}
+	}
+
+	sig := sign(now)
+
+	tests := []struct {
+		name   string
+		path   string
+		header map[string]string
+		status int
+	}{
+		{"no auth", "/send/user1", nil, http.StatusUnauthorized},
+		{"bearer", "/send/user1", map[string]string{"Authorization": "Bearer key1"}, http.StatusOK},
+		{"api key", "/send/user1", map[string]string{"X-Api-Key": "key1"}, http.StatusOK},
+		{"bad key", "/send/user1", map[string]string{"X-Api-Key": "key2"}, http.StatusUnauthorized},
+		{"other recipient", "/send/user2", map[string]string{"X-Api-Key": "key1"}, http.StatusForbidden},
+		{"basic", "/grafana", map[string]string{"Authorization": "Basic Z3JhZmFuYTpwYXNz"}, http.StatusOK},
+		{"bad password", "/grafana", map[string]string{"Authorization": "Basic Z3JhZmFuYTp4eHg="}, http.StatusUnauthorized},
+		{"no scope", "/grafana", map[string]string{"Authorization": "Bearer key1"}, http.StatusForbidden},
+		{"signature", "/grafana", sig, http.StatusOK},
+		{"bad signature", "/grafana", map[string]string{"X-Signature": "sha256=00ff", "X-Timestamp": sig["X-Timestamp"]}, http.StatusUnauthorized},
+		{"no timestamp", "/grafana", map[string]string{"X-Signature": sig["X-Signature"]}, http.StatusUnauthorized},
+		{"other timestamp", "/grafana", map[string]string{"X-Signature": sig["X-Signature"], "X-Timestamp": "1"}, http.StatusUnauthorized},
+		{"replay", "/grafana", sign(now.Add(-time.Minute * 6)), http.StatusUnauthorized},
+		{"future", "/grafana", sign(now.Add(time.Minute * 6)), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader("body"))
+
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := a.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+
+	var noAuth *HttpAuth
+	a2 := fiber.New()
+	a2.Get("/", noAuth.scope(ScopeAdmin), func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	resp, err := a2.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -1,32 +1,76 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"html"
+	"sort"
 	"strings"
 	"time"
 
 	"botik/cmd/botik/alert"
+	"botik/cmd/botik/audit"
+	"botik/cmd/botik/outbox"
+	"botik/internal/util"
 
 	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/gofiber/fiber/v2"
 )
 
-func runHttpServer(app *App) {
+// HttpServer is an api server for alerts and messages.
+type HttpServer struct {
+	srv  *fiber.App
+	addr string
+	app  *App
+}
+
+func NewHttpServer(app *App) *HttpServer {
 	a := fiber.New(fiber.Config{DisableStartupMessage: true})
 	//a.Use(logger.New())
 
-	a.Post("/send/:name", SendHandlerFunc(app))
-	a.Post("/grafana", GrafanaHandlerFunc(app))
-	a.Post("/api/v2/alerts", AlertsHandlerFunc(app))
-	a.Get("/api/alerts", GetAlertsHandlerFunc(app))
-	a.Get("/api/alerts/:id/mute", GetMuteAlertHandlerFunc(app))
+	auth := app.auth
 
-	app.logger.Info("start listener on " + app.conf.Listen())
+	if auth == nil {
+		app.logger.Warn("http api has no authentication, set http_auth.credentials")
+	}
+
+	sendScope := func(c *fiber.Ctx) string {
+		return ScopeSend + ":" + strings.ToLower(c.Params("name"))
+	}
 
-	if err := a.Listen(app.conf.Listen()); err != nil {
-		app.logger.Error("server error", "error", err)
+	a.Post("/send/:name", audited(app, "send"), auth.require(sendScope), SendHandlerFunc(app))
+	a.Post("/grafana", auth.scope(ScopeIngest), GrafanaHandlerFunc(app))
+	a.Post("/api/v2/alerts", auth.scope(ScopeIngest), AlertsHandlerFunc(app))
+	a.Post("/api/alertmanager", auth.scope(ScopeIngest), AlertmanagerHandlerFunc(app))
+	a.Get("/api/alerts", auth.scope(ScopeRead), GetAlertsHandlerFunc(app))
+	a.Get("/api/alerts/history", auth.scope(ScopeRead), GetAlertsHistoryHandlerFunc(app))
+	a.Post("/api/alerts/:id/mute", audited(app, "mute"), auth.scope(ScopeAdmin), MuteAlertHandlerFunc(app))
+	a.Get("/api/silences", auth.scope(ScopeRead), GetSilencesHandlerFunc(app))
+	a.Post("/api/silences", audited(app, "silence"), auth.scope(ScopeAdmin), PostSilenceHandlerFunc(app))
+	a.Delete("/api/silences/:id", audited(app, "unmute"), auth.scope(ScopeAdmin), DeleteSilenceHandlerFunc(app))
+	a.Get("/api/audit", auth.scope(ScopeAdmin), GetAuditHandlerFunc(app))
+	a.Get("/api/outbox", auth.scope(ScopeRead), GetOutboxHandlerFunc(app))
+
+	if app.webhook != nil && app.webhook.Mounted() {
+		a.Post(app.webhook.path, app.webhook.Handler())
 	}
+
+	return &HttpServer{srv: a, addr: app.conf.Listen(), app: app}
+}
+
+func (h *HttpServer) Start() {
+	h.app.logger.Info("start listener on " + h.addr)
+
+	go func() {
+		if err := h.srv.Listen(h.addr); err != nil {
+			h.app.logger.Error("server error", "error", err)
+		}
+	}()
+}
+
+// Stop waits for active requests to finish till ctx is done.
+func (h *HttpServer) Stop(ctx context.Context) error {
+	return h.srv.ShutdownWithContext(ctx)
 }
 
 type GrafanaReq struct {
@@ -48,16 +92,6 @@ type GrafanaReq struct {
 	Title string `json:"title"`
 }
 
-type AlertReq struct {
-	StartsAt     time.Time         `json:"startsAt"`
-	EndsAt       time.Time         `json:"endsAt"`
-	GeneratorURL string            `json:"generatorURL"`
-	Labels       map[string]string `json:"labels"`
-	Annotations  struct {
-		Summary string `json:"summary"`
-	} `json:"annotations"`
-}
-
 func SendHandlerFunc(app *App) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		name := c.Params("name")
@@ -83,8 +117,28 @@ func SendHandlerFunc(app *App) fiber.Handler {
 			return c.SendString("ok")
 		}
 
-		app.logger.Warn("user not found: " + name)
-		return c.SendStatus(fiber.StatusNotFound)
+		// not a user or group, route it as a channel if there is a route for it
+		labels := map[string]string{"source": "send", "channel": name}
+		ids := app.receivers(labels)
+
+		if len(ids) == 0 || !app.router.Routed(labels) {
+			app.logger.Warn("user not found: " + name)
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+
+		body := c.Body()
+
+		if len(body) == 0 {
+			return c.SendString("empty body")
+		}
+
+		for _, id := range ids {
+			if _, err := app.sendTgWithMode(id, html.EscapeString(string(body)), "HTML"); err != nil {
+				return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+			}
+		}
+
+		return c.SendString("ok")
 	}
 }
 
@@ -96,15 +150,9 @@ func GrafanaHandlerFunc(app *App) fiber.Handler {
 		}
 
 		text := MakeGrafanaMsg(r)
+		labels := map[string]string{"source": "grafana", "alertname": r.RuleName, "state": r.State}
 
-		for _, user := range app.conf.Strings("notify") {
-			id, err := app.IdByName(user)
-
-			if err != nil {
-				app.logger.Error("invalid user " + user)
-				continue
-			}
-
+		for _, id := range app.receivers(labels) {
 			if _, err := app.sendTg(id, text); err != nil {
 				return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 			}
@@ -113,17 +161,24 @@ func GrafanaHandlerFunc(app *App) fiber.Handler {
 	}
 }
 
+// AlertsHandlerFunc gets alerts posted by vmalert to Alertmanager API v2. In push mode alerts are taken as is,
+// otherwise they are polled from vmalert.
 func AlertsHandlerFunc(app *App) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		list := make([]*AlertReq, 0)
+		list := make([]*alert.PushedAlert, 0)
 
 		if err := c.BodyParser(&list); err != nil {
 			return err
 		}
 
+		if app.conf.String("alerts.mode") == "push" {
+			app.am.Push(alert.SourceVmalert, "", list)
+			return c.SendString("ok")
+		}
+
 		for _, a := range list {
-			app.logger.Info("new alert url: " + string(c.Body()))
 			url := strings.ReplaceAll(a.GeneratorURL, "/vmalert/alert?", "/api/v1/alert?")
+			app.logger.Info("new alert url: "+url, "fingerprint", a.Fingerprint)
 			app.am.AddUrl(url)
 		}
 
@@ -131,6 +186,24 @@ func AlertsHandlerFunc(app *App) fiber.Handler {
 	}
 }
 
+func AlertmanagerHandlerFunc(app *App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		msg := new(alert.WebhookMessage)
+
+		if err := c.BodyParser(msg); err != nil {
+			return err
+		}
+
+		if msg.Version != "" && msg.Version != "4" {
+			app.logger.Warn("unknown alertmanager webhook version " + msg.Version)
+		}
+
+		app.am.PushWebhook(msg)
+
+		return c.SendString("ok")
+	}
+}
+
 func GetAlertsHandlerFunc(app *App) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		list := make([]*alert.AlertRecDTO, 0)
@@ -144,44 +217,275 @@ func GetAlertsHandlerFunc(app *App) fiber.Handler {
 	}
 }
 
-func GetMuteAlertHandlerFunc(app *App) fiber.Handler {
+type SilenceReq struct {
+	alert.Silence
+	Duration string `json:"duration,omitempty"`
+}
+
+// GetAlertsHistoryHandlerFunc returns alert events since ?since= (duration like 24h or RFC3339 time) with
+// labels matching all ?label=name=value params.
+func GetAlertsHistoryHandlerFunc(app *App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		since := time.Now().Add(-time.Hour * 24)
+
+		if s := c.Query("since"); s != "" {
+			if d, err := util.ParseDuration(s); err == nil {
+				since = time.Now().Add(-d)
+			} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+				since = t
+			} else {
+				return c.Status(fiber.StatusBadRequest).SendString("invalid since " + s)
+			}
+		}
+
+		labels := make(map[string]string)
+
+		for _, l := range c.Context().QueryArgs().PeekMulti("label") {
+			k, v, ok := strings.Cut(string(l), "=")
+
+			if !ok {
+				return c.Status(fiber.StatusBadRequest).SendString("invalid label " + string(l))
+			}
+
+			labels[k] = v
+		}
+
+		events, err := app.am.History(since, labels)
+
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+
+		return c.JSON(events)
+	}
+}
+
+// MuteAlertHandlerFunc mutes alert for duration from "for" parameter, forever if it is empty.
+func MuteAlertHandlerFunc(app *App) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 
-		app.am.Range(func(ar *alert.AlertRec) bool {
-			if ar.Alert().ID == id {
-				ar.Mute()
+		var d time.Duration
+
+		// author is the api credential, not a request parameter anyone can set
+		by, _ := c.Locals("user").(string)
+		if by == "" {
+			by = "api"
+		}
+
+		if s := c.Query("for"); s != "" {
+			var err error
+			if d, err = util.ParseDuration(s); err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 			}
-			return true
-		})
+		}
+
+		ar := app.am.FindAlert(id)
+
+		if ar == nil {
+			return c.Status(fiber.StatusNotFound).SendString("alert " + id + " is not found")
+		}
+
+		if _, err := app.am.MuteAlert(ar, d, by); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
 
 		return c.SendString("ok")
 	}
 }
 
+func GetSilencesHandlerFunc(app *App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(app.am.Silences())
+	}
+}
+
+func PostSilenceHandlerFunc(app *App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		r := new(SilenceReq)
+		if err := c.BodyParser(r); err != nil {
+			return err
+		}
+
+		s := &r.Silence
+		s.ID = ""
+
+		if r.Duration != "" {
+			d, err := util.ParseDuration(r.Duration)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+
+			if s.StartsAt.IsZero() {
+				s.StartsAt = time.Now()
+			}
+			s.EndsAt = s.StartsAt.Add(d)
+		}
+
+		if s.CreatedBy == "" {
+			s.CreatedBy = "http"
+		}
+
+		if err := app.am.AddSilence(s); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+
+		return c.JSON(s)
+	}
+}
+
+func DeleteSilenceHandlerFunc(app *App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := app.am.ExpireSilence(c.Params("id")); err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+
+		return c.SendString("ok")
+	}
+}
+
+// audited records api call to audit log, user is the authenticated client name or client address.
+func audited(app *App, cmd string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		params := make([]string, 0)
+		for k, v := range c.AllParams() {
+			params = append(params, k+"="+v)
+		}
+
+		sort.Strings(params)
+
+		user, _ := c.Locals("user").(string)
+		if user == "" {
+			user = c.IP()
+		}
+
+		e := &audit.Entry{
+			Source:   audit.SourceHttp,
+			User:     user,
+			Msg:      c.Method() + " " + c.OriginalURL(),
+			Answerer: "api",
+			Cmd:      cmd,
+			Payload:  strings.Join(params, " "),
+		}
+
+		switch status := c.Response().StatusCode(); {
+		case err != nil:
+			e.Outcome = audit.OutcomeError
+			e.Error = err.Error()
+		case status == fiber.StatusUnauthorized || status == fiber.StatusForbidden:
+			e.Outcome = audit.OutcomeDenied
+		case status >= 400:
+			e.Outcome = audit.OutcomeError
+			e.Error = fmt.Sprintf("status %d: %s", status, c.Response().Body())
+		}
+
+		app.auditor.Record(e)
+
+		return err
+	}
+}
+
+func GetAuditHandlerFunc(app *App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := &audit.Filter{Since: time.Now().Add(-time.Hour * 24), User: c.Query("user"), Answerer: c.Query("answerer")}
+
+		if s := c.Query("since"); s != "" {
+			if d, err := util.ParseDuration(s); err == nil {
+				f.Since = time.Now().Add(-d)
+			} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+				f.Since = t
+			} else {
+				return c.Status(fiber.StatusBadRequest).SendString("invalid since " + s)
+			}
+		}
+
+		entries, err := app.auditor.Query(f)
+
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+
+		return c.JSON(entries)
+	}
+}
+
+func GetOutboxHandlerFunc(app *App) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if app.out == nil {
+			return c.JSON(outbox.Metrics{})
+		}
+
+		return c.JSON(app.out.Metrics())
+	}
+}
+
 func (app *App) sendTg(id int64, text string) (int, error) {
 	return app.sendTgWithMode(id, text, "MarkdownV2")
 }
 
 func (app *App) sendTgWithMode(id int64, text string, mode string) (int, error) {
-	logger := app.logger.With("id", id)
-
-	if app.bot == nil {
-		logger.Warn("bot is not ready")
-		return 0, fmt.Errorf("bot is not connected")
-	}
+	return app.sendTgWithMarkup(id, text, mode, nil)
+}
 
+func (app *App) sendTgWithMarkup(id int64, text string, mode string, kb *tg.InlineKeyboardMarkup) (int, error) {
 	msg := tg.NewMessage(id, text)
 	msg.ParseMode = mode
-	msg1, err := app.bot.Send(msg)
+
+	if kb != nil {
+		msg.ReplyMarkup = kb
+	}
+
+	return app.sendTgMessage(msg, outbox.PriorityNormal)
+}
+
+func (app *App) sendTgMessage(msg tg.MessageConfig, p outbox.Priority) (int, error) {
+	msg1, err := app.send(msg.ChatID, msg, p)
 
 	if err != nil {
-		logger.Error("can't send message", "error", err)
+		return 0, err
 	}
 
 	return msg1.MessageID, nil
 }
 
+// send puts message to outbox and waits till it is sent.
+func (app *App) send(chatID int64, c tg.Chattable, p outbox.Priority) (tg.Message, error) {
+	if app.out == nil {
+		app.logger.Warn("bot is not ready", "id", chatID)
+		return tg.Message{}, fmt.Errorf("bot is not connected")
+	}
+
+	return app.out.Send(context.Background(), &outbox.Message{ChatID: chatID, Msg: c, Priority: p})
+}
+
+// sendAsync puts message to outbox without waiting, errors are logged.
+func (app *App) sendAsync(chatID int64, c tg.Chattable, p outbox.Priority) {
+	app.enqueue(&outbox.Message{ChatID: chatID, Msg: c, Priority: p})
+}
+
+// enqueue puts message to outbox without waiting, the result goes to m.OnResult.
+func (app *App) enqueue(m *outbox.Message) {
+	if app.out == nil {
+		app.logger.Warn("bot is not ready", "id", m.ChatID)
+		return
+	}
+
+	app.out.Enqueue(m)
+}
+
+// editTg replaces text and inline keyboard of the sent message.
+func (app *App) editTg(chatID int64, msgID int, text string, mode string, kb *tg.InlineKeyboardMarkup) error {
+	msg := tg.NewEditMessageText(chatID, msgID, text)
+	msg.ParseMode = mode
+	msg.ReplyMarkup = kb
+
+	_, err := app.send(chatID, msg, outbox.PriorityHigh)
+
+	return err
+}
+
 func MakeGrafanaMsg(r *GrafanaReq) string {
 	if r == nil {
 		return "empty message"
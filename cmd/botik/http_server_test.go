@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"botik/cmd/botik/alert"
+	"botik/cmd/botik/audit"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuteAlertHandler(t *testing.T) {
+	log := audit.NewMemoryLog()
+	app := &App{
+		logger:  slog.Default(),
+		am:      alert.NewManager(slog.Default(), func(n *alert.Notification) []alert.Delivery { return nil }),
+		auditor: audit.New(slog.Default(), log, 0),
+	}
+
+	app.am.Push("test", "grp", []*alert.PushedAlert{{Status: "firing", Labels: map[string]string{"alertname": "a"}, Fingerprint: "abc"}})
+	require.NotNil(t, app.am.FindAlert("abc"))
+
+	a := fiber.New()
+	a.Post("/api/alerts/:id/mute", audited(app, "mute"), MuteAlertHandlerFunc(app))
+
+	resp, err := a.Test(httptest.NewRequest(http.MethodPost, "/api/alerts/xyz/mute?for=1h", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Empty(t, app.am.Silences())
+
+	resp, err = a.Test(httptest.NewRequest(http.MethodPost, "/api/alerts/abc/mute?for=1h&by=someone", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, app.am.Silences(), 1)
+	// author can't be set by request without auth
+	assert.Equal(t, "api", app.am.Silences()[0].CreatedBy)
+
+	entries, err := log.Query(&audit.Filter{Answerer: "api"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, audit.OutcomeError, entries[0].Outcome)
+	assert.Equal(t, audit.OutcomeOk, entries[1].Outcome)
+	assert.NotEqual(t, "someone", entries[1].User)
+}
@@ -6,17 +6,20 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
-	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"botik/cmd/botik/alert"
 	"botik/cmd/botik/answer"
+	"botik/cmd/botik/audit"
+	"botik/cmd/botik/outbox"
+	"botik/cmd/botik/route"
 
 	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/kdudkov/goatak/pkg/cot"
@@ -27,13 +30,24 @@ var (
 	gitBranch   string
 )
 
+const defaultShutdownTimeout = time.Second * 10
+
 type App struct {
-	conf   *AppConfig
-	bot    *tg.BotAPI
-	cl     *MqttClient
-	logger *slog.Logger
-	am     *alert.AlertManager
-	ans    *answer.AnswerManager
+	conf    *AppConfig
+	bot     *tg.BotAPI
+	cl      *MqttClient
+	logger  *slog.Logger
+	am      *alert.AlertManager
+	ans     *answer.AnswerManager
+	router  *route.Route
+	srv     *HttpServer
+	auth    *HttpAuth
+	webhook *Webhook
+	auditor *audit.Auditor
+	out     *outbox.Outbox
+	outConf *outbox.Config
+	// handlers are in-flight update handlers
+	handlers sync.WaitGroup
 }
 
 func NewApp(conf *AppConfig) *App {
@@ -43,16 +57,52 @@ func NewApp(conf *AppConfig) *App {
 		ans:    answer.New(),
 	}
 
+	router, err := conf.Route()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	app.router = router
+
 	app.am = alert.NewManager(slog.Default().With("logger", "alerts"), app.alertNotifier)
 
+	alertConf := new(alert.Config)
+	if err := conf.Unmarshal("alerts", alertConf); err != nil {
+		panic(err.Error())
+	}
+
+	if err := app.am.Configure(alertConf); err != nil {
+		panic(err.Error())
+	}
+
+	authConf := new(HttpAuthConfig)
+	if err := conf.Unmarshal("http_auth", authConf); err != nil {
+		panic(err.Error())
+	}
+
+	app.auth = NewHttpAuth(authConf)
+
+	app.outConf = new(outbox.Config)
+	if err := conf.Unmarshal("outbox", app.outConf); err != nil {
+		panic(err.Error())
+	}
+
+	if s := app.conf.String("alerts.state_file"); s != "" {
+		app.am.SetStore(alert.NewFileStore(s))
+	}
+
+	if s := app.conf.String("alerts.history_file"); s != "" {
+		app.am.SetHistory(alert.NewFileHistory(s))
+	}
+
 	if s := app.conf.String("mahno.host"); s != "" {
-		if err := app.ans.RegisterAnswer("light", answer.NewLight(app.logger, s)); err != nil {
+		if err := app.ans.RegisterAnswer("light", answer.NewLight(app.logger, s), answer.PriorityNormal); err != nil {
 			panic(err.Error())
 		}
 	}
 
 	if s := app.conf.String("camera.file"); s != "" {
-		if err := app.ans.RegisterAnswer("cam", answer.NewCamera(app.logger, s)); err != nil {
+		if err := app.ans.RegisterAnswer("cam", answer.NewCamera(app.logger, s), answer.PriorityNormal); err != nil {
 			panic(err.Error())
 		}
 	}
@@ -61,38 +111,49 @@ func NewApp(conf *AppConfig) *App {
 		app.cl = NewMqttClient(app.logger, app.conf, app.onMessage)
 	}
 
-	app.ans.RegisterAnswer("alerts", answer.NewAlerts(app.logger, app.am))
+	if err := app.ans.RegisterAnswer("alerts", answer.NewAlerts(app.logger, app.am), answer.PriorityHigh); err != nil {
+		panic(err.Error())
+	}
 
-	return app
-}
+	retention := conf.Duration("audit.retention")
+	if retention == 0 {
+		retention = time.Hour * 24 * 30
+	}
 
-func (app *App) GetUpdatesChannel() (tg.UpdatesChannel, error) {
-	if webhook := app.conf.String("webhook.ext"); webhook != "" {
-		app.logger.Info("starting webhook " + webhook)
+	var auditLog audit.Log = audit.NewMemoryLog()
+	if s := app.conf.String("audit.file"); s != "" {
+		auditLog = audit.NewFileLog(s)
+	}
 
-		wh, _ := tg.NewWebhook(webhook)
-		if _, err := app.bot.Request(wh); err != nil {
-			return nil, err
-		}
+	app.auditor = audit.New(app.logger, auditLog, retention)
+	app.ans.SetAuditor(app.auditor)
+	if err := app.ans.RegisterAnswer("audit", answer.NewAudit(app.auditor), answer.PriorityHigh); err != nil {
+		panic(err.Error())
+	}
 
-		info, err := app.bot.GetWebhookInfo()
-		if err != nil {
-			return nil, err
+	if conf.Exists("roles") {
+		roles := make(map[string][]string)
+		userRoles := make(map[string][]string)
+
+		if err := conf.Unmarshal("roles", &roles); err != nil {
+			panic(err.Error())
 		}
 
-		if info.LastErrorDate != 0 {
-			app.logger.Error("Telegram callback failed", "error", info.LastErrorMessage)
-			return nil, fmt.Errorf("callback errror with %s", info.LastErrorMessage)
+		if err := conf.Unmarshal("user_roles", &userRoles); err != nil {
+			panic(err.Error())
 		}
 
-		app.logger.Info(fmt.Sprintf("start listener on %s, path %s", app.conf.String("webhook.listen"), app.conf.String("webhook.path")))
-		go func() {
-			if err := http.ListenAndServe(app.conf.String("webhook.listen"), nil); err != nil {
-				panic(err)
-			}
-		}()
+		app.ans.SetAccess(answer.NewAccess(roles, userRoles))
+	}
+
+	return app
+}
 
-		return app.bot.ListenForWebhook(app.conf.String("webhook.path")), nil
+func (app *App) GetUpdatesChannel() (tg.UpdatesChannel, error) {
+	if app.webhook != nil {
+		app.logger.Info("starting webhook " + app.conf.String("webhook.ext"))
+
+		return app.webhook.Start()
 	}
 
 	app.logger.Info("start polling")
@@ -103,9 +164,54 @@ func (app *App) GetUpdatesChannel() (tg.UpdatesChannel, error) {
 	return app.bot.GetUpdatesChan(u), nil
 }
 
+// quit stops taking updates and requests, waits for in-flight handlers till drain deadline and stops subsystems.
 func (app *App) quit() {
+	timeout := app.conf.Duration("shutdown_timeout")
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
 	app.bot.StopReceivingUpdates()
-	if webhook := app.conf.String("webhook.ext"); webhook != "" {
+
+	if app.webhook != nil {
+		if err := app.webhook.Stop(ctx); err != nil {
+			app.logger.Error("webhook listener shutdown error", "error", err)
+		}
+	}
+
+	if err := app.srv.Stop(ctx); err != nil {
+		app.logger.Error("http server shutdown error", "error", err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		app.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		app.logger.Warn("drain deadline exceeded, some updates are not processed")
+	}
+
+	app.am.Stop()
+
+	if app.cl != nil {
+		app.cl.Stop()
+	}
+
+	// the drain may have used up the deadline, outbox gets its own time to flush or spool messages
+	outCtx, outCancel := context.WithTimeout(context.Background(), timeout)
+	defer outCancel()
+
+	app.out.Stop(outCtx)
+
+	if app.webhook != nil {
 		app.removeWebhook()
 	}
 }
@@ -116,7 +222,7 @@ func (app *App) removeWebhook() {
 	}
 }
 
-func (app *App) Run() {
+func (app *App) Run(ctx context.Context) {
 	// for k := range app.users {
 	// 	app.logger.Info("user " + k)
 	// }
@@ -128,12 +234,33 @@ func (app *App) Run() {
 		panic("can't start bot " + err.Error())
 	}
 	app.logger.Info("registering " + app.bot.Self.String())
+	app.publishCommands()
+
+	app.out = outbox.New(app.logger, app.bot, app.outConf)
+
+	if s := app.conf.String("outbox.spool_file"); s != "" {
+		if err := app.out.SetSpool(outbox.NewFileSpool(s)); err != nil {
+			app.logger.Error("can't load outbox spool", "error", err)
+		}
+	}
+
+	// outbox is stopped in quit after everything that sends messages
+	app.out.Start(context.Background())
+
+	if app.conf.String("webhook.ext") != "" {
+		if app.webhook, err = NewWebhook(app); err != nil {
+			panic("can't start webhook " + err.Error())
+		}
+	}
 
-	go runHttpServer(app)
-	app.am.Start()
+	app.srv = NewHttpServer(app)
+	app.srv.Start()
+
+	// alerts and mqtt are stopped in quit after in-flight handlers are drained, not on signal
+	app.am.Start(context.Background())
 
 	if app.cl != nil {
-		go app.cl.Run(context.TODO())
+		app.cl.Start(context.Background())
 	}
 
 	updates, err := app.GetUpdatesChannel()
@@ -143,14 +270,21 @@ func (app *App) Run() {
 		return
 	}
 
-	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
-
 	for {
 		select {
-		case update := <-updates:
-			go app.Process(update)
-		case <-sigc:
+		case update, ok := <-updates:
+			if !ok {
+				app.quit()
+				return
+			}
+
+			app.handlers.Add(1)
+
+			go func() {
+				defer app.handlers.Done()
+				app.Process(update)
+			}()
+		case <-ctx.Done():
 			app.logger.Info("quit")
 			app.quit()
 			return
@@ -162,47 +296,121 @@ func (app *App) onMessage(topic string, msg []byte) {
 	chunks := strings.Split(topic, "/")
 
 	if len(chunks) == 4 && chunks[3] == "snapshot" {
-		for _, user := range app.conf.Strings("notify") {
-			id, err := app.IdByName(user)
+		labels := map[string]string{"source": "frigate", "camera": chunks[1], "label": chunks[2], "type": "snapshot"}
+
+		for _, id := range app.receivers(labels) {
+			app.sendAsync(id, tg.NewPhoto(id, tg.FileBytes{Bytes: msg, Name: fmt.Sprintf("cam %s %s", chunks[1], chunks[2])}), outbox.PriorityLow)
+		}
+	}
+
+	if topic == "frigate/reviews" {
+		app.ProcessReview(msg)
+	}
+}
+
+func (app *App) alertNotifier(n *alert.Notification) []alert.Delivery {
+	if len(n.Edit) > 0 {
+		for _, d := range n.Edit {
+			msg := tg.NewEditMessageText(d.ChatID, d.MessageID, n.Text)
+			msg.ParseMode = "HTML"
+			msg.ReplyMarkup = alertKeyboard(n)
+
+			logger := app.logger.With("id", d.ChatID)
+
+			app.enqueue(&outbox.Message{ChatID: d.ChatID, Msg: msg, Priority: outbox.PriorityHigh, OnResult: func(r outbox.Result) {
+				if r.Err != nil {
+					logger.Error("can't edit message", slog.Any("error", r.Err))
+				}
+			}})
+		}
+
+		return nil
+	}
+
+	var targets []alert.Delivery
+
+	switch {
+	case len(n.ReplyTo) > 0:
+		targets = n.ReplyTo
+	case len(n.Receivers) > 0:
+		for _, name := range n.Receivers {
+			id, err := app.IdByName(name)
 
 			if err != nil {
-				app.logger.Error("invalid user "+user, slog.Any("error", err))
+				app.logger.Error("invalid user "+name, slog.Any("error", err))
 				continue
 			}
 
-			msg := tg.NewPhoto(id, tg.FileBytes{Bytes: msg, Name: fmt.Sprintf("cam %s %s", chunks[1], chunks[2])})
+			targets = append(targets, alert.Delivery{ChatID: id})
+		}
+	default:
+		for _, id := range app.receivers(n.Labels()) {
+			targets = append(targets, alert.Delivery{ChatID: id})
+		}
+	}
 
-			if _, err := app.bot.Send(msg); err != nil {
-				app.logger.Error("can't send message", slog.Any("error", err))
-			}
+	// alert manager must not wait for telegram, sent messages are reported to it later
+	for _, t := range targets {
+		chatID := t.ChatID
+		logger := app.logger.With("id", chatID)
+		logger.Info("sending notification")
+
+		msg := tg.NewMessage(chatID, n.Text)
+		msg.ParseMode = "HTML"
+		msg.ReplyToMessageID = t.MessageID
+
+		if kb := alertKeyboard(n); kb != nil {
+			msg.ReplyMarkup = kb
 		}
+
+		app.enqueue(&outbox.Message{ChatID: chatID, Msg: msg, Priority: alertPriority(n), OnResult: func(r outbox.Result) {
+			if r.Err != nil {
+				logger.Error("error send message", slog.Any("error", r.Err))
+				return
+			}
+
+			if n.Delivered != nil {
+				n.Delivered(alert.Delivery{ChatID: chatID, MessageID: r.Message.MessageID})
+			}
+		}})
 	}
 
-	if topic == "frigate/reviews" {
-		app.ProcessReview(msg)
+	return nil
+}
+
+// alertPriority lets critical alerts go before anything else.
+func alertPriority(n *alert.Notification) outbox.Priority {
+	if n.Labels()["severity"] == "critical" {
+		return outbox.PriorityCritical
 	}
+
+	return outbox.PriorityHigh
 }
 
-func (app *App) alertNotifier(text string) {
-	for _, user := range app.conf.Strings("notify") {
-		id, err := app.IdByName(user)
+// receivers returns chat ids for notification with labels according to routing tree.
+func (app *App) receivers(labels map[string]string) []int64 {
+	res := make([]int64, 0)
+
+	for _, name := range app.router.Find(labels) {
+		id, err := app.IdByName(name)
 
 		if err != nil {
-			app.logger.Error("invalid user "+user, slog.Any("error", err))
+			app.logger.Error("invalid user "+name, slog.Any("error", err))
 			continue
 		}
 
-		go func(logger *slog.Logger, id int64, text string) {
-			logger.Info("sending notification")
-
-			if _, err := app.sendTgWithMode(id, text, "HTML"); err != nil {
-				logger.Error("error send message", slog.Any("error", err))
-			}
-		}(app.logger.With("user", user, "id", id), id, text)
+		res = append(res, id)
 	}
+
+	return res
 }
 
 func (app *App) Process(update tg.Update) {
+	if update.CallbackQuery != nil {
+		app.processCallback(update.CallbackQuery)
+		return
+	}
+
 	var message *tg.Message
 
 	if update.EditedMessage != nil {
@@ -223,12 +431,19 @@ func (app *App) Process(update tg.Update) {
 
 	logger := app.logger.With(slog.String("from", message.From.UserName), slog.Int64("id", message.From.ID))
 
+	// in groups bot answers only to commands, mentions and replies to its messages
+	text, ok := addressed(message, app.bot.Self)
+
 	user := app.getUser(message.From.ID)
 
 	if user == "" {
+		if !ok {
+			return
+		}
+
 		logger.Info(fmt.Sprintf("unknown user, msg: %s", message.Text))
 		msg := tg.NewMessage(message.Chat.ID, "с незнакомыми не разговариваю")
-		_, err := app.bot.Send(msg)
+		_, err := app.send(message.Chat.ID, msg, outbox.PriorityNormal)
 
 		if err != nil {
 			logger.Error("can't send message", slog.Any("error", err))
@@ -246,7 +461,11 @@ func (app *App) Process(update tg.Update) {
 		}
 	}
 
-	if message.Text == "" {
+	if !ok {
+		return
+	}
+
+	if text == "" {
 		logger.Info("empty message")
 		return
 	}
@@ -259,21 +478,25 @@ func (app *App) Process(update tg.Update) {
 		replText = message.ReplyToMessage.Text
 	}
 
-	ans := app.ans.CheckAnswer(user, message.Text, replText)
-	var msg tg.Chattable
+	ans := app.ans.CheckAnswer(&answer.Request{User: user, Chat: message.Chat.ID, Msg: text, Repl: replText,
+		Answerers: app.groupAnswerers(message.Chat.ID), Lang: message.From.LanguageCode})
 
-	if ans.Photo != "" {
-		msg = tg.NewPhoto(message.Chat.ID, tg.FilePath(ans.Photo))
-	} else {
-		msg = tg.NewMessage(message.Chat.ID, ans.Msg)
+	if ans == nil {
+		logger.Warn("no answer to " + text)
+		return
 	}
 
-	//msg.ReplyToMessageID = update.Message.MessageID
-
-	_, err := app.bot.Send(msg)
+	// in groups answer is a reply to the member's message
+	replyTo := 0
+	if !message.Chat.IsPrivate() {
+		replyTo = message.MessageID
+	}
 
-	if err != nil {
-		logger.Error("can't send message", slog.Any("error", err))
+	for _, msg := range answerMessages(message.Chat.ID, ans, replyTo) {
+		if _, err := app.send(message.Chat.ID, msg, outbox.PriorityHigh); err != nil {
+			logger.Error("can't send message", slog.Any("error", err))
+			return
+		}
 	}
 }
 
@@ -360,6 +583,9 @@ func main() {
 
 	slog.SetDefault(slog.New(h))
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	app := NewApp(conf)
-	app.Run()
+	app.Run(ctx)
 }
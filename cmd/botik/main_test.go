@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"botik/cmd/botik/alert"
+	"botik/cmd/botik/outbox"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowSender sends messages when they are released.
+type slowSender struct {
+	release chan struct{}
+}
+
+func (s *slowSender) Send(c tg.Chattable) (tg.Message, error) {
+	<-s.release
+	return tg.Message{MessageID: 42}, nil
+}
+
+func TestAlertNotifierAsync(t *testing.T) {
+	s := &slowSender{release: make(chan struct{})}
+	o := outbox.New(slog.Default(), s, &outbox.Config{GlobalRate: 1000, ChatRate: 1000})
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	app := &App{logger: slog.Default(), out: o}
+
+	delivered := make(chan alert.Delivery, 1)
+	n := &alert.Notification{Text: "alert", ReplyTo: []alert.Delivery{{ChatID: 1, MessageID: 3}},
+		Delivered: func(d alert.Delivery) { delivered <- d }}
+
+	// notifier returns before telegram answers
+	assert.Nil(t, app.alertNotifier(n))
+
+	close(s.release)
+
+	select {
+	case d := <-delivered:
+		assert.Equal(t, alert.Delivery{ChatID: 1, MessageID: 42}, d)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "notification is not delivered")
+	}
+}
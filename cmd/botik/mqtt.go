@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log/slog"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -28,6 +29,9 @@ type MqttClient struct {
 	logger        *slog.Logger
 	config        MqttConfig
 	cb            func(topic string, payload []byte)
+	ctx           context.Context
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
 }
 
 type Message struct {
@@ -77,28 +81,71 @@ func (m *MqttClient) isConnected() bool {
 	return atomic.LoadInt32(&m.mqttConnected) == 1
 }
 
-func (m *MqttClient) Run(ctx context.Context) {
+// Start connects to broker and runs sender till ctx is done or Stop is called.
+func (m *MqttClient) Start(ctx context.Context) {
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+		m.run(m.ctx)
+	}()
+}
+
+// Stop sends queued messages and disconnects from broker.
+func (m *MqttClient) Stop() {
+	if m.cancel == nil {
+		return
+	}
+
+	m.cancel()
+	m.wg.Wait()
+
+	if m.isConnected() {
+		m.flush()
+	}
+
+	m.client.Disconnect(250)
+	m.logger.Info("mqtt client stopped")
+}
+
+func (m *MqttClient) run(ctx context.Context) {
 	m.Connect()
-	
+
 	for {
 		select {
 		case <-ctx.Done():
 			m.logger.Info("stopping sender")
 			return
 		case msg := <-m.sendQueue:
-			token := m.client.Publish(msg.Topic, msg.Qos, false, []byte(msg.Payload))
-			if !token.WaitTimeout(tokenTimeout) {
-				m.logger.Error("send timeout")
-				break
-			}
-
-			if token.Error() != nil {
-				m.logger.Error("publish error", "error", token.Error())
-			}
+			m.publish(msg)
+		}
+	}
+}
+
+func (m *MqttClient) flush() {
+	for {
+		select {
+		case msg := <-m.sendQueue:
+			m.publish(msg)
+		default:
+			return
 		}
 	}
 }
 
+func (m *MqttClient) publish(msg *Message) {
+	token := m.client.Publish(msg.Topic, msg.Qos, false, []byte(msg.Payload))
+	if !token.WaitTimeout(tokenTimeout) {
+		m.logger.Error("send timeout")
+		return
+	}
+
+	if token.Error() != nil {
+		m.logger.Error("publish error", "error", token.Error())
+	}
+}
+
 func (m *MqttClient) tryConnect() error {
 	if m.isConnected() {
 		return nil
@@ -124,7 +171,13 @@ func (m *MqttClient) Connect() {
 		if err := m.tryConnect(); err == nil {
 			return
 		}
-		time.Sleep(timeout)
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(timeout):
+		}
+
 		if timeout < time.Second*30 {
 			timeout *= 2
 		}
@@ -144,7 +197,9 @@ func (m *MqttClient) onConnected(_ mqtt.Client) {
 func (m *MqttClient) onDisconnected(_ mqtt.Client, err error) {
 	m.setConnected(false)
 	m.logger.Info("MQTT disconnected", slog.Any("error", err))
-	time.AfterFunc(time.Second, m.Connect)
+	if m.ctx.Err() == nil {
+		time.AfterFunc(time.Second, m.Connect)
+	}
 }
 
 func (m *MqttClient) onReceive(_ mqtt.Client, msg mqtt.Message) {
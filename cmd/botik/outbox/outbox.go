@@ -0,0 +1,658 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+var (
+	ErrClosed  = errors.New("outbox is closed")
+	ErrSpooled = errors.New("message is not delivered and is spooled")
+)
+
+// Sender sends telegram messages, *tg.BotAPI is a Sender.
+type Sender interface {
+	Send(c tg.Chattable) (tg.Message, error)
+}
+
+// albumSender sends media groups, Send fails on their result which is a list of messages.
+type albumSender interface {
+	SendMediaGroup(c tg.MediaGroupConfig) ([]tg.Message, error)
+}
+
+// Config sets outbox limits, Telegram allows about 30 messages per second overall,
+// one message per second to a chat and 20 messages per minute to a group.
+type Config struct {
+	// GlobalRate is a max number of messages per second to all chats
+	GlobalRate float64 `koanf:"global_rate"`
+	// ChatRate is a max number of messages per second to a private chat
+	ChatRate float64 `koanf:"chat_rate"`
+	// GroupRate is a max number of messages per second to a group chat
+	GroupRate float64 `koanf:"group_rate"`
+	// MaxRetries is how many times to retry message after transient error
+	MaxRetries int `koanf:"max_retries"`
+	// Workers is a number of concurrent requests to telegram
+	Workers int `koanf:"workers"`
+	// MaxAge is an age of spooled message after which it is collapsed into offline summary, never if zero
+	MaxAge time.Duration `koanf:"max_age"`
+	// ReplayInterval is how often to try to send spooled messages
+	ReplayInterval time.Duration `koanf:"replay_interval"`
+}
+
+func (c *Config) setDefaults() {
+	if c.GlobalRate <= 0 {
+		c.GlobalRate = 30
+	}
+
+	if c.ChatRate <= 0 {
+		c.ChatRate = 1
+	}
+
+	if c.GroupRate <= 0 {
+		c.GroupRate = 20.0 / 60
+	}
+
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 5
+	}
+
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+
+	if c.ReplayInterval <= 0 {
+		c.ReplayInterval = time.Second * 30
+	}
+}
+
+// Message is a queued telegram message.
+type Message struct {
+	ChatID   int64
+	Msg      tg.Chattable
+	Priority Priority
+	Created  time.Time
+	// OnResult is called with the result of the message when it is sent, spooled or failed
+	OnResult func(Result)
+
+	seq      uint64
+	attempts int
+	next     time.Time
+	res      chan Result
+	// replay is set for spooled message, it is tried once and is not spooled again
+	replay bool
+}
+
+type Result struct {
+	Message tg.Message
+	Err     error
+}
+
+type Metrics struct {
+	Queued  int64 `json:"queued"`
+	Sent    int64 `json:"sent"`
+	Failed  int64 `json:"failed"`
+	Retried int64 `json:"retried"`
+	Spooled int64 `json:"spooled"`
+}
+
+// Outbox sends messages one by one per chat, highest priority first, keeping to rate limits.
+type Outbox struct {
+	logger *slog.Logger
+	sender Sender
+	conf   *Config
+	now    func() time.Time
+
+	mx     sync.Mutex
+	queue  []*Message
+	seq    uint64
+	chats  map[int64]*chatState
+	global time.Time
+	// inflight is a number of messages being sent
+	inflight int
+	closed   bool
+	wake     chan struct{}
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	sem      chan struct{}
+	spool    Spool
+	// spooled are undelivered messages in order, new messages go there too till they are replayed
+	spooled []*Record
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	retried atomic.Int64
+}
+
+type chatState struct {
+	next time.Time
+	busy bool
+}
+
+func New(logger *slog.Logger, sender Sender, conf *Config) *Outbox {
+	if conf == nil {
+		conf = new(Config)
+	}
+
+	conf.setDefaults()
+
+	return &Outbox{
+		logger: logger.With("logger", "outbox"),
+		sender: sender,
+		conf:   conf,
+		now:    time.Now,
+		chats:  make(map[int64]*chatState),
+		wake:   make(chan struct{}, 1),
+		sem:    make(chan struct{}, conf.Workers),
+	}
+}
+
+// SetSpool sets storage for undelivered messages and loads messages left there.
+func (o *Outbox) SetSpool(sp Spool) error {
+	recs, err := sp.Load()
+	if err != nil {
+		return err
+	}
+
+	o.mx.Lock()
+	defer o.mx.Unlock()
+
+	o.spool = sp
+	o.spooled = recs
+
+	return nil
+}
+
+// Start runs dispatcher and replayer till ctx is done or Stop is called.
+func (o *Outbox) Start(ctx context.Context) {
+	ctx, o.cancel = context.WithCancel(ctx)
+	o.wg.Add(2)
+
+	go func() {
+		defer o.wg.Done()
+		o.dispatcher(ctx)
+	}()
+
+	go func() {
+		defer o.wg.Done()
+		o.replayer(ctx)
+	}()
+}
+
+// Stop stops taking new messages and waits till queued ones are sent or ctx is done,
+// messages left are spooled or failed with ErrClosed.
+func (o *Outbox) Stop(ctx context.Context) {
+	o.mx.Lock()
+	o.closed = true
+	o.mx.Unlock()
+
+	defer func() {
+		if o.cancel != nil {
+			o.cancel()
+		}
+
+		o.wg.Wait()
+		o.drop()
+	}()
+
+	for o.pending() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Millisecond * 50):
+		}
+	}
+}
+
+// Enqueue puts message to queue, result will be sent to returned channel.
+func (o *Outbox) Enqueue(m *Message) <-chan Result {
+	m.res = make(chan Result, 1)
+
+	if m.Created.IsZero() {
+		m.Created = o.now()
+	}
+
+	o.mx.Lock()
+
+	if o.closed {
+		o.mx.Unlock()
+		m.done(Result{Err: ErrClosed})
+
+		return m.res
+	}
+
+	// keep order while there are undelivered messages
+	if len(o.spooled) > 0 && !m.replay && o.spoolMessage(m) {
+		o.mx.Unlock()
+		m.done(Result{Err: ErrSpooled})
+
+		return m.res
+	}
+
+	o.seq++
+	m.seq = o.seq
+	o.queue = append(o.queue, m)
+	o.mx.Unlock()
+
+	o.notify()
+
+	return m.res
+}
+
+// done sends result of the message to its channel and callback.
+func (m *Message) done(r Result) {
+	m.res <- r
+
+	if m.OnResult != nil {
+		m.OnResult(r)
+	}
+}
+
+// Send puts message to queue and waits till it is sent.
+func (o *Outbox) Send(ctx context.Context, m *Message) (tg.Message, error) {
+	select {
+	case res := <-o.Enqueue(m):
+		return res.Message, res.Err
+	case <-ctx.Done():
+		return tg.Message{}, ctx.Err()
+	}
+}
+
+func (o *Outbox) Len() int {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+
+	return len(o.queue)
+}
+
+func (o *Outbox) pending() int {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+
+	return len(o.queue) + o.inflight
+}
+
+func (o *Outbox) Metrics() Metrics {
+	return Metrics{
+		Queued:  int64(o.Len()),
+		Sent:    o.sent.Load(),
+		Failed:  o.failed.Load(),
+		Retried: o.retried.Load(),
+		Spooled: int64(o.spoolLen()),
+	}
+}
+
+func (o *Outbox) spoolLen() int {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+
+	return len(o.spooled)
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) dispatcher(ctx context.Context) {
+	for {
+		m, wait := o.pick()
+
+		if m != nil {
+			select {
+			case o.sem <- struct{}{}:
+			case <-ctx.Done():
+				o.mx.Lock()
+				o.inflight--
+				o.chat(m.ChatID).busy = false
+				o.mx.Unlock()
+
+				o.fail(m, ErrClosed)
+				return
+			}
+
+			o.wg.Add(1)
+
+			go func() {
+				defer func() {
+					<-o.sem
+					o.wg.Done()
+				}()
+
+				o.send(m)
+			}()
+
+			continue
+		}
+
+		t := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-o.wake:
+		case <-t.C:
+		}
+
+		t.Stop()
+	}
+}
+
+// pick takes the best message that can be sent now, or returns how long to wait for one.
+func (o *Outbox) pick() (*Message, time.Duration) {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+
+	now := o.now()
+	wait := time.Minute
+	best := -1
+
+	for i, m := range o.queue {
+		cs := o.chat(m.ChatID)
+
+		if cs.busy {
+			continue
+		}
+
+		at := latest(m.next, cs.next, o.global)
+
+		if at.After(now) {
+			wait = min(wait, at.Sub(now))
+			continue
+		}
+
+		if best == -1 || m.Priority > o.queue[best].Priority || (m.Priority == o.queue[best].Priority && m.seq < o.queue[best].seq) {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return nil, wait
+	}
+
+	m := o.queue[best]
+	o.queue = append(o.queue[:best], o.queue[best+1:]...)
+
+	o.inflight++
+
+	cs := o.chat(m.ChatID)
+	cs.busy = true
+	cs.next = now.Add(o.interval(m.ChatID))
+	o.global = now.Add(rateInterval(o.conf.GlobalRate))
+
+	return m, 0
+}
+
+// deliver sends message, the result of an album is its first message.
+func (o *Outbox) deliver(c tg.Chattable) (tg.Message, error) {
+	if mg, ok := c.(tg.MediaGroupConfig); ok {
+		if s, ok := o.sender.(albumSender); ok {
+			msgs, err := s.SendMediaGroup(mg)
+
+			if err != nil || len(msgs) == 0 {
+				return tg.Message{}, err
+			}
+
+			return msgs[0], nil
+		}
+	}
+
+	return o.sender.Send(c)
+}
+
+func (o *Outbox) send(m *Message) {
+	msg, err := o.deliver(m.Msg)
+
+	o.mx.Lock()
+	o.inflight--
+	cs := o.chat(m.ChatID)
+	cs.busy = false
+
+	if err == nil {
+		o.mx.Unlock()
+		o.notify()
+		o.sent.Add(1)
+		m.done(Result{Message: msg})
+
+		return
+	}
+
+	retryAfter, transient := classify(err)
+
+	if transient && !m.replay && m.attempts >= o.conf.MaxRetries && o.spoolMessage(m) {
+		o.mx.Unlock()
+		o.notify()
+		o.logger.Warn(fmt.Sprintf("message to %d is spooled", m.ChatID), "error", err.Error())
+		m.done(Result{Err: ErrSpooled})
+
+		return
+	}
+
+	if !transient || m.replay || m.attempts >= o.conf.MaxRetries {
+		o.mx.Unlock()
+		o.notify()
+		o.fail(m, err)
+
+		return
+	}
+
+	m.attempts++
+
+	if retryAfter == 0 {
+		retryAfter = backoff(m.attempts)
+	}
+
+	m.next = o.now().Add(retryAfter)
+	cs.next = latest(cs.next, m.next)
+
+	o.queue = append(o.queue, m)
+	o.mx.Unlock()
+
+	o.retried.Add(1)
+	o.logger.Warn(fmt.Sprintf("retry message to %d in %s", m.ChatID, retryAfter), "error", err.Error(), "attempt", m.attempts)
+	o.notify()
+}
+
+func (o *Outbox) fail(m *Message, err error) {
+	o.failed.Add(1)
+	o.logger.Error(fmt.Sprintf("can't send message to %d", m.ChatID), "error", err.Error())
+	m.done(Result{Err: err})
+}
+
+// drop spools or fails all queued messages.
+func (o *Outbox) drop() {
+	o.mx.Lock()
+	q := o.queue
+	o.queue = nil
+
+	var failed, spooled []*Message
+
+	for _, m := range q {
+		if m.replay || !o.spoolMessage(m) {
+			failed = append(failed, m)
+			continue
+		}
+
+		spooled = append(spooled, m)
+	}
+
+	o.mx.Unlock()
+
+	for _, m := range spooled {
+		m.done(Result{Err: ErrSpooled})
+	}
+
+	for _, m := range failed {
+		o.fail(m, ErrClosed)
+	}
+}
+
+// spoolMessage saves message to spool, it returns false if there is no spool or message can't be spooled.
+// Must be called with o.mx locked.
+func (o *Outbox) spoolMessage(m *Message) bool {
+	if o.spool == nil {
+		return false
+	}
+
+	r := NewRecord(m)
+
+	if r == nil {
+		return false
+	}
+
+	i := len(o.spooled)
+	for i > 0 && o.spooled[i-1].Created.After(r.Created) {
+		i--
+	}
+
+	o.spooled = slices.Insert(o.spooled, i, r)
+	o.saveSpool()
+
+	return true
+}
+
+// saveSpool must be called with o.mx locked.
+func (o *Outbox) saveSpool() {
+	if err := o.spool.Save(o.spooled); err != nil {
+		o.logger.Error("can't save spool", "error", err.Error())
+	}
+}
+
+func (o *Outbox) replayer(ctx context.Context) {
+	ticker := time.NewTicker(o.conf.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.replay(ctx)
+		}
+	}
+}
+
+// replay sends spooled messages in order till the first failure.
+func (o *Outbox) replay(ctx context.Context) {
+	o.mx.Lock()
+
+	if len(o.spooled) == 0 {
+		o.mx.Unlock()
+		return
+	}
+
+	if recs := collapse(o.spooled, o.now(), o.conf.MaxAge); !slices.Equal(recs, o.spooled) {
+		o.spooled = recs
+		o.saveSpool()
+	}
+
+	o.mx.Unlock()
+
+	for {
+		o.mx.Lock()
+
+		if len(o.spooled) == 0 {
+			o.mx.Unlock()
+			o.logger.Info("all spooled messages are sent")
+
+			return
+		}
+
+		r := o.spooled[0]
+		o.mx.Unlock()
+
+		m := r.Message()
+		m.replay = true
+
+		if _, err := o.Send(ctx, m); err != nil {
+			if _, transient := classify(err); transient {
+				return
+			}
+		}
+
+		o.mx.Lock()
+		if len(o.spooled) > 0 && o.spooled[0] == r {
+			o.spooled = o.spooled[1:]
+			o.saveSpool()
+		}
+		o.mx.Unlock()
+	}
+}
+
+func (o *Outbox) chat(id int64) *chatState {
+	cs, ok := o.chats[id]
+
+	if !ok {
+		cs = new(chatState)
+		o.chats[id] = cs
+	}
+
+	return cs
+}
+
+// interval returns min interval between messages to chat, groups have negative ids.
+func (o *Outbox) interval(chatID int64) time.Duration {
+	if chatID < 0 {
+		return rateInterval(o.conf.GroupRate)
+	}
+
+	return rateInterval(o.conf.ChatRate)
+}
+
+// classify tells if error is worth retrying and how long to wait if telegram has told it.
+func classify(err error) (time.Duration, bool) {
+	var tgErr *tg.Error
+
+	if !errors.As(err, &tgErr) {
+		// network error
+		return 0, true
+	}
+
+	switch {
+	case tgErr.Code == 429 || tgErr.RetryAfter > 0:
+		return time.Duration(tgErr.RetryAfter) * time.Second, true
+	case tgErr.Code >= 500:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+func backoff(attempt int) time.Duration {
+	return min(time.Second<<(attempt-1), time.Minute)
+}
+
+func rateInterval(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
+
+func latest(ts ...time.Time) time.Time {
+	var res time.Time
+
+	for _, t := range ts {
+		if t.After(res) {
+			res = t
+		}
+	}
+
+	return res
+}
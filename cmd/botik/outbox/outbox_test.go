@@ -0,0 +1,199 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSender struct {
+	mx   sync.Mutex
+	sent []string
+	errs []error
+}
+
+func (f *fakeSender) Send(c tg.Chattable) (tg.Message, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+
+		if err != nil {
+			return tg.Message{}, err
+		}
+	}
+
+	var text string
+
+	switch msg := c.(type) {
+	case tg.MessageConfig:
+		text = msg.Text
+	case tg.EditMessageTextConfig:
+		text = msg.Text
+	}
+
+	f.sent = append(f.sent, text)
+
+	return tg.Message{MessageID: len(f.sent), Text: text}, nil
+}
+
+func (f *fakeSender) Sent() []string {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	return append([]string(nil), f.sent...)
+}
+
+func message(chatID int64, text string, p Priority) *Message {
+	return &Message{ChatID: chatID, Msg: tg.NewMessage(chatID, text), Priority: p}
+}
+
+func TestPriority(t *testing.T) {
+	s := new(fakeSender)
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 1000, Workers: 1})
+
+	// queue messages before start to let dispatcher choose
+	var res []<-chan Result
+	res = append(res, o.Enqueue(message(1, "snapshot", PriorityLow)))
+	res = append(res, o.Enqueue(message(2, "send", PriorityNormal)))
+	res = append(res, o.Enqueue(message(3, "critical", PriorityCritical)))
+	res = append(res, o.Enqueue(message(4, "send2", PriorityNormal)))
+
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	for _, ch := range res {
+		r := <-ch
+		require.NoError(t, r.Err)
+	}
+
+	assert.Equal(t, []string{"critical", "send", "send2", "snapshot"}, s.Sent())
+	assert.Equal(t, Metrics{Sent: 4}, o.Metrics())
+}
+
+type fakeAlbumSender struct {
+	fakeSender
+}
+
+func (a *fakeAlbumSender) SendMediaGroup(c tg.MediaGroupConfig) ([]tg.Message, error) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.sent = append(a.sent, "album")
+
+	return []tg.Message{{MessageID: 10}, {MessageID: 11}}, nil
+}
+
+func TestAlbum(t *testing.T) {
+	s := new(fakeAlbumSender)
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 1000})
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	album := tg.NewMediaGroup(1, []interface{}{tg.NewInputMediaPhoto(tg.FilePath("1.jpg")), tg.NewInputMediaPhoto(tg.FilePath("2.jpg"))})
+
+	msg, err := o.Send(context.Background(), &Message{ChatID: 1, Msg: album})
+	require.NoError(t, err)
+	assert.Equal(t, 10, msg.MessageID)
+	assert.Equal(t, []string{"album"}, s.Sent())
+}
+
+func TestChatRate(t *testing.T) {
+	s := new(fakeSender)
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 10})
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	start := time.Now()
+
+	var res []<-chan Result
+	for range 3 {
+		res = append(res, o.Enqueue(message(1, "msg", PriorityNormal)))
+	}
+
+	for _, ch := range res {
+		require.NoError(t, (<-ch).Err)
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*200)
+}
+
+func TestRetry(t *testing.T) {
+	s := &fakeSender{errs: []error{
+		&tg.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tg.ResponseParameters{RetryAfter: 1}},
+		errors.New("connection reset"),
+	}}
+
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 1000})
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	start := time.Now()
+	msg, err := o.Send(context.Background(), message(1, "hello", PriorityHigh))
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", msg.Text)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second*2)
+	assert.Equal(t, int64(2), o.Metrics().Retried)
+}
+
+func TestPermanentError(t *testing.T) {
+	s := &fakeSender{errs: []error{&tg.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}}}
+
+	o := New(slog.Default(), s, nil)
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	_, err := o.Send(context.Background(), message(1, "hello", PriorityNormal))
+	require.Error(t, err)
+
+	assert.Empty(t, s.Sent())
+	assert.Equal(t, Metrics{Failed: 1}, o.Metrics())
+}
+
+func TestStop(t *testing.T) {
+	s := new(fakeSender)
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 1000})
+	o.Start(context.Background())
+
+	res := o.Enqueue(message(1, "last", PriorityNormal))
+	o.Stop(context.Background())
+
+	require.NoError(t, (<-res).Err)
+	assert.Equal(t, []string{"last"}, s.Sent())
+
+	_, err := o.Send(context.Background(), message(1, "late", PriorityNormal))
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestOnResult(t *testing.T) {
+	s := new(fakeSender)
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 1000})
+
+	res := make(chan Result, 1)
+	m := message(1, "text", PriorityNormal)
+	m.OnResult = func(r Result) { res <- r }
+
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	o.Enqueue(m)
+
+	select {
+	case r := <-res:
+		require.NoError(t, r.Err)
+	case <-time.After(time.Second * 5):
+		require.Fail(t, "no result")
+	}
+
+	assert.Equal(t, []string{"text"}, s.Sent())
+}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"botik/internal/jsonfile"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Spool keeps undelivered messages between restarts.
+type Spool interface {
+	Load() ([]*Record, error)
+	Save(recs []*Record) error
+}
+
+// Record is a message that could not be delivered. Only text messages and photos can be spooled.
+type Record struct {
+	ChatID    int64                    `json:"chat_id"`
+	Priority  Priority                 `json:"priority"`
+	Created   time.Time                `json:"created"`
+	Text      string                   `json:"text,omitempty"`
+	ParseMode string                   `json:"parse_mode,omitempty"`
+	ReplyTo   int                      `json:"reply_to,omitempty"`
+	Keyboard  *tg.InlineKeyboardMarkup `json:"keyboard,omitempty"`
+	Photo     string                   `json:"photo,omitempty"`
+	PhotoName string                   `json:"photo_name,omitempty"`
+	PhotoData []byte                   `json:"photo_data,omitempty"`
+	// Summary is set for "while you were offline" message
+	Summary bool `json:"summary,omitempty"`
+}
+
+// NewRecord makes a record of message, it returns nil if message can't be spooled.
+func NewRecord(m *Message) *Record {
+	r := &Record{ChatID: m.ChatID, Priority: m.Priority, Created: m.Created}
+
+	switch c := m.Msg.(type) {
+	case tg.MessageConfig:
+		r.Text = c.Text
+		r.ParseMode = c.ParseMode
+		r.ReplyTo = c.ReplyToMessageID
+
+		switch kb := c.ReplyMarkup.(type) {
+		case *tg.InlineKeyboardMarkup:
+			r.Keyboard = kb
+		case tg.InlineKeyboardMarkup:
+			r.Keyboard = &kb
+		}
+	case tg.PhotoConfig:
+		r.Text = c.Caption
+		r.ParseMode = c.ParseMode
+
+		switch f := c.File.(type) {
+		case tg.FilePath:
+			r.Photo = string(f)
+		case tg.FileBytes:
+			r.PhotoName = f.Name
+			r.PhotoData = f.Bytes
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	return r
+}
+
+func (r *Record) Message() *Message {
+	m := &Message{ChatID: r.ChatID, Priority: r.Priority, Created: r.Created}
+
+	switch {
+	case r.Photo != "":
+		p := tg.NewPhoto(r.ChatID, tg.FilePath(r.Photo))
+		p.Caption = r.Text
+		p.ParseMode = r.ParseMode
+		m.Msg = p
+	case r.PhotoData != nil:
+		p := tg.NewPhoto(r.ChatID, tg.FileBytes{Name: r.PhotoName, Bytes: r.PhotoData})
+		p.Caption = r.Text
+		p.ParseMode = r.ParseMode
+		m.Msg = p
+	default:
+		msg := tg.NewMessage(r.ChatID, r.Text)
+		msg.ParseMode = r.ParseMode
+		msg.ReplyToMessageID = r.ReplyTo
+
+		if r.Keyboard != nil {
+			msg.ReplyMarkup = r.Keyboard
+		}
+
+		m.Msg = msg
+	}
+
+	return m
+}
+
+var tagRe = regexp.MustCompile(`<[^>]+>`)
+
+// title is the first line of message without markup.
+func (r *Record) title() string {
+	s := r.Text
+
+	if r.ParseMode == "HTML" {
+		s = tagRe.ReplaceAllString(s, "")
+	}
+
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+
+	if len([]rune(s)) > 80 {
+		s = string([]rune(s)[:79]) + "…"
+	}
+
+	if r.Photo != "" || r.PhotoData != nil {
+		return strings.TrimSpace("[фото] " + s)
+	}
+
+	return s
+}
+
+const summaryLines = 10
+
+// collapse replaces messages older than maxAge with one summary message per chat.
+func collapse(recs []*Record, now time.Time, maxAge time.Duration) []*Record {
+	if maxAge <= 0 {
+		return recs
+	}
+
+	stale := make(map[int64][]*Record)
+
+	for _, r := range recs {
+		if !r.Summary && now.Sub(r.Created) > maxAge {
+			stale[r.ChatID] = append(stale[r.ChatID], r)
+		}
+	}
+
+	if len(stale) == 0 {
+		return recs
+	}
+
+	res := make([]*Record, 0, len(recs))
+
+	for _, r := range recs {
+		old, ok := stale[r.ChatID]
+
+		switch {
+		case r.Summary || now.Sub(r.Created) <= maxAge:
+			res = append(res, r)
+		case ok && old[0] == r:
+			res = append(res, summary(old))
+		}
+	}
+
+	return res
+}
+
+func summary(recs []*Record) *Record {
+	first, last := recs[0], recs[len(recs)-1]
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Пока не было связи, не доставлено сообщений: %d (%s - %s)\n",
+		len(recs), first.Created.Format("02.01 15:04"), last.Created.Format("02.01 15:04"))
+
+	for i, r := range recs {
+		if i == summaryLines {
+			fmt.Fprintf(&sb, "\n... и еще %d", len(recs)-summaryLines)
+			break
+		}
+
+		fmt.Fprintf(&sb, "\n%s %s", r.Created.Format("15:04"), r.title())
+	}
+
+	p := first.Priority
+	for _, r := range recs {
+		p = max(p, r.Priority)
+	}
+
+	return &Record{ChatID: first.ChatID, Priority: p, Created: last.Created, Text: sb.String(), Summary: true}
+}
+
+type MemorySpool struct {
+	recs []*Record
+	mx   sync.Mutex
+}
+
+func NewMemorySpool() *MemorySpool {
+	return &MemorySpool{}
+}
+
+func (m *MemorySpool) Load() ([]*Record, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return m.recs, nil
+}
+
+func (m *MemorySpool) Save(recs []*Record) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.recs = recs
+
+	return nil
+}
+
+// FileSpool keeps undelivered messages as a json file, rewriting it atomically on every save.
+type FileSpool struct {
+	path string
+	mx   sync.Mutex
+}
+
+func NewFileSpool(path string) *FileSpool {
+	return &FileSpool{path: path}
+}
+
+func (f *FileSpool) Load() ([]*Record, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	var recs []*Record
+
+	if _, err := jsonfile.Load(f.path, &recs); err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
+
+func (f *FileSpool) Save(recs []*Record) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	return jsonfile.Save(f.path, recs)
+}
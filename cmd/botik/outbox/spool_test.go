@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	msg := tg.NewMessage(1, "<b>alert</b>\ndetails")
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tg.NewInlineKeyboardMarkup(tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("mute", "al|mute|1|1h")))
+
+	r := NewRecord(&Message{ChatID: 1, Msg: msg, Priority: PriorityCritical})
+	require.NotNil(t, r)
+	assert.Equal(t, "alert", r.title())
+
+	m := r.Message()
+	assert.Equal(t, PriorityCritical, m.Priority)
+
+	msg1 := m.Msg.(tg.MessageConfig)
+	assert.Equal(t, msg.Text, msg1.Text)
+	assert.Equal(t, "HTML", msg1.ParseMode)
+	assert.NotNil(t, msg1.ReplyMarkup)
+
+	r = NewRecord(&Message{ChatID: 1, Msg: tg.NewPhoto(1, tg.FileBytes{Name: "cam", Bytes: []byte{1, 2}})})
+	require.NotNil(t, r)
+	assert.Equal(t, "[фото]", r.title())
+
+	assert.Nil(t, NewRecord(&Message{ChatID: 1, Msg: tg.NewEditMessageText(1, 2, "edit")}))
+}
+
+func TestCollapse(t *testing.T) {
+	now := time.Now()
+
+	recs := []*Record{
+		{ChatID: 1, Text: "old1", Created: now.Add(-time.Hour * 3)},
+		{ChatID: 2, Text: "old2", Created: now.Add(-time.Hour * 3)},
+		{ChatID: 1, Text: "old3", Created: now.Add(-time.Hour * 2), Priority: PriorityCritical},
+		{ChatID: 1, Text: "new", Created: now.Add(-time.Minute)},
+	}
+
+	res := collapse(recs, now, time.Hour)
+	require.Len(t, res, 3)
+
+	assert.True(t, res[0].Summary)
+	assert.Equal(t, int64(1), res[0].ChatID)
+	assert.Equal(t, PriorityCritical, res[0].Priority)
+	assert.Contains(t, res[0].Text, "old1")
+	assert.Contains(t, res[0].Text, "old3")
+
+	assert.True(t, res[1].Summary)
+	assert.Equal(t, int64(2), res[1].ChatID)
+
+	assert.Equal(t, "new", res[2].Text)
+
+	// summaries are not collapsed again
+	res2 := collapse(res, now.Add(time.Hour*10), time.Hour)
+	require.Len(t, res2, 3)
+	assert.Same(t, res[0], res2[0])
+	assert.Same(t, res[1], res2[1])
+	assert.True(t, res2[2].Summary)
+}
+
+func TestSpoolReplay(t *testing.T) {
+	netErr := errors.New("connection refused")
+	s := &fakeSender{errs: []error{netErr, netErr}}
+
+	sp := NewFileSpool(filepath.Join(t.TempDir(), "spool.json"))
+
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 1000, MaxRetries: 1, ReplayInterval: time.Millisecond * 100})
+	require.NoError(t, o.SetSpool(sp))
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	_, err := o.Send(context.Background(), message(1, "first", PriorityNormal))
+	assert.ErrorIs(t, err, ErrSpooled)
+
+	// spool is not empty, so message goes after the first one
+	_, err = o.Send(context.Background(), message(1, "second", PriorityCritical))
+	assert.ErrorIs(t, err, ErrSpooled)
+
+	// edits can't be spooled, so they are sent as usual
+	_, err = o.Send(context.Background(), &Message{ChatID: 1, Msg: tg.NewEditMessageText(1, 1, "edit")})
+	assert.NoError(t, err)
+
+	require.Eventually(t, func() bool { return o.Metrics().Spooled == 0 }, time.Second*5, time.Millisecond*50)
+	assert.ElementsMatch(t, []string{"edit", "first", "second"}, s.Sent())
+	assert.NotContains(t, strings.Join(s.Sent(), ","), "second,first")
+
+	recs, err := sp.Load()
+	require.NoError(t, err)
+	assert.Empty(t, recs)
+}
+
+func TestSpoolRestore(t *testing.T) {
+	sp := NewMemorySpool()
+	now := time.Now()
+
+	require.NoError(t, sp.Save([]*Record{
+		{ChatID: 1, Text: "stale", Created: now.Add(-time.Hour * 2)},
+		{ChatID: 1, Text: "fresh", Created: now},
+	}))
+
+	s := new(fakeSender)
+	o := New(slog.Default(), s, &Config{GlobalRate: 1000, ChatRate: 1000, MaxAge: time.Hour, ReplayInterval: time.Millisecond * 100})
+	require.NoError(t, o.SetSpool(sp))
+	o.Start(context.Background())
+	defer o.Stop(context.Background())
+
+	require.Eventually(t, func() bool { return len(s.Sent()) == 2 }, time.Second*5, time.Millisecond*50)
+
+	sent := s.Sent()
+	assert.True(t, strings.HasPrefix(sent[0], "Пока не было связи"))
+	assert.Contains(t, sent[0], "stale")
+	assert.Equal(t, "fresh", sent[1])
+}
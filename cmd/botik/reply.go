@@ -0,0 +1,159 @@
+package main
+
+import (
+	"botik/cmd/botik/answer"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegram album takes 2-10 photos
+const maxAlbum = 10
+
+// answerMessages translates answer to telegram messages to the chat. replyTo is the message to reply to
+// if answer does not set it, 0 for none.
+func answerMessages(chatID int64, ans *answer.Answer, replyTo int) []tg.Chattable {
+	if ans.ReplyTo != 0 {
+		replyTo = ans.ReplyTo
+	}
+
+	if ans.Edit != 0 {
+		m := tg.NewEditMessageText(chatID, ans.Edit, ans.Msg)
+		m.ParseMode = ans.ParseMode
+
+		if kb, ok := replyMarkup(ans.Keyboard).(tg.InlineKeyboardMarkup); ok {
+			m.ReplyMarkup = &kb
+		}
+
+		return []tg.Chattable{m}
+	}
+
+	markup := replyMarkup(ans.Keyboard)
+
+	// in groups the answer to bot question must be a reply to be addressed to the bot
+	if ans.Ask != nil && replyTo != 0 {
+		markup = tg.ForceReply{ForceReply: true, Selective: true}
+	}
+
+	base := func(b *tg.BaseChat) {
+		b.ReplyToMessageID = replyTo
+		b.DisableNotification = ans.Silent
+		b.ReplyMarkup = markup
+	}
+
+	photos := ans.Photos
+	if ans.Photo != "" {
+		photos = append([]string{ans.Photo}, photos...)
+	}
+
+	switch {
+	case len(photos) > 1:
+		res := make([]tg.Chattable, 0)
+
+		for i := 0; i < len(photos); i += maxAlbum {
+			chunk := photos[i:min(i+maxAlbum, len(photos))]
+
+			// the rest of one photo can't be an album
+			if len(chunk) == 1 {
+				m := tg.NewPhoto(chatID, tg.FilePath(chunk[0]))
+				m.DisableNotification = ans.Silent
+				res = append(res, m)
+
+				continue
+			}
+
+			files := make([]interface{}, 0, maxAlbum)
+
+			for j, p := range chunk {
+				ph := tg.NewInputMediaPhoto(tg.FilePath(p))
+
+				if i == 0 && j == 0 {
+					ph.Caption, ph.ParseMode = ans.Msg, ans.ParseMode
+				}
+
+				files = append(files, ph)
+			}
+
+			m := tg.NewMediaGroup(chatID, files)
+			m.ReplyToMessageID = replyTo
+			m.DisableNotification = ans.Silent
+			res = append(res, m)
+		}
+
+		return res
+
+	case len(photos) == 1:
+		m := tg.NewPhoto(chatID, tg.FilePath(photos[0]))
+		m.Caption, m.ParseMode = ans.Msg, ans.ParseMode
+		base(&m.BaseChat)
+
+		return []tg.Chattable{m}
+
+	case ans.Document != nil:
+		var f tg.RequestFileData = tg.FilePath(ans.Document.Path)
+
+		if ans.Document.Data != nil {
+			f = tg.FileBytes{Name: ans.Document.Name, Bytes: ans.Document.Data}
+		}
+
+		m := tg.NewDocument(chatID, f)
+		m.Caption, m.ParseMode = ans.Msg, ans.ParseMode
+		base(&m.BaseChat)
+
+		return []tg.Chattable{m}
+
+	default:
+		m := tg.NewMessage(chatID, ans.Msg)
+		m.ParseMode = ans.ParseMode
+		base(&m.BaseChat)
+
+		return []tg.Chattable{m}
+	}
+}
+
+func replyMarkup(k *answer.Keyboard) interface{} {
+	switch {
+	case k == nil:
+		return nil
+
+	case k.Remove:
+		return tg.NewRemoveKeyboard(true)
+
+	case k.Inline:
+		rows := make([][]tg.InlineKeyboardButton, 0, len(k.Rows))
+
+		for _, r := range k.Rows {
+			row := make([]tg.InlineKeyboardButton, 0, len(r))
+
+			for _, b := range r {
+				if b.URL != "" {
+					row = append(row, tg.NewInlineKeyboardButtonURL(b.Text, b.URL))
+				} else {
+					row = append(row, tg.NewInlineKeyboardButtonData(b.Text, b.Data))
+				}
+			}
+
+			rows = append(rows, row)
+		}
+
+		return tg.NewInlineKeyboardMarkup(rows...)
+
+	default:
+		rows := make([][]tg.KeyboardButton, 0, len(k.Rows))
+
+		for _, r := range k.Rows {
+			row := make([]tg.KeyboardButton, 0, len(r))
+
+			for _, b := range r {
+				row = append(row, tg.NewKeyboardButton(b.Text))
+			}
+
+			rows = append(rows, row)
+		}
+
+		kb := tg.NewReplyKeyboard(rows...)
+		kb.OneTimeKeyboard = k.OneTime
+		kb.Selective = true
+
+		return kb
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"botik/cmd/botik/answer"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnswerMessages(t *testing.T) {
+	msgs := answerMessages(1, &answer.Answer{Msg: "*жирный*", ParseMode: answer.ModeMarkdown, Silent: true}, 5)
+	require.Len(t, msgs, 1)
+
+	m := msgs[0].(tg.MessageConfig)
+	assert.Equal(t, "*жирный*", m.Text)
+	assert.Equal(t, tg.ModeMarkdownV2, m.ParseMode)
+	assert.Equal(t, 5, m.ReplyToMessageID)
+	assert.True(t, m.DisableNotification)
+	assert.Nil(t, m.ReplyMarkup)
+
+	// inline keyboard, answer sets message to reply to
+	a := &answer.Answer{Msg: "alert", ReplyTo: 7, Keyboard: answer.InlineKeyboard(
+		[]answer.Button{{Text: "mute", Data: "mute:1"}, {Text: "grafana", URL: "https://grafana"}})}
+	m = answerMessages(1, a, 5)[0].(tg.MessageConfig)
+	assert.Equal(t, 7, m.ReplyToMessageID)
+
+	kb := m.ReplyMarkup.(tg.InlineKeyboardMarkup)
+	require.Len(t, kb.InlineKeyboard, 1)
+	assert.Equal(t, "mute:1", *kb.InlineKeyboard[0][0].CallbackData)
+	assert.Equal(t, "https://grafana", *kb.InlineKeyboard[0][1].URL)
+
+	// reply keyboard in private chat, force reply to question in group
+	a = &answer.Answer{Msg: "где?", Ask: &answer.Question{}, Keyboard: answer.ReplyKeyboard("на кухне", "в комнате")}
+	rk := answerMessages(1, a, 0)[0].(tg.MessageConfig).ReplyMarkup.(tg.ReplyKeyboardMarkup)
+	assert.True(t, rk.OneTimeKeyboard)
+	assert.Equal(t, "в комнате", rk.Keyboard[1][0].Text)
+	assert.IsType(t, tg.ForceReply{}, answerMessages(1, a, 5)[0].(tg.MessageConfig).ReplyMarkup)
+
+	// edit
+	e := answerMessages(1, &answer.Answer{Msg: "new", Edit: 3, Keyboard: answer.InlineKeyboard()}, 5)[0].(tg.EditMessageTextConfig)
+	assert.Equal(t, 3, e.MessageID)
+	assert.Equal(t, "new", e.Text)
+	assert.NotNil(t, e.ReplyMarkup)
+
+	// photo with caption
+	p := answerMessages(1, answer.PhotoAnswer("cam.jpg"), 0)[0].(tg.PhotoConfig)
+	assert.Equal(t, tg.FilePath("cam.jpg"), p.File)
+
+	// document from memory
+	d := answerMessages(1, answer.DocumentAnswer("export", &answer.File{Name: "a.csv", Data: []byte("a,b")}), 0)[0].(tg.DocumentConfig)
+	assert.Equal(t, "export", d.Caption)
+	assert.Equal(t, tg.FileBytes{Name: "a.csv", Bytes: []byte("a,b")}, d.File)
+
+	// album of 11 photos is an album of 10 and a photo
+	var photos []string
+	for range 11 {
+		photos = append(photos, "p.jpg")
+	}
+
+	msgs = answerMessages(1, answer.AlbumAnswer("фото", photos...), 0)
+	require.Len(t, msgs, 2)
+
+	album := msgs[0].(tg.MediaGroupConfig)
+	assert.Len(t, album.Media, 10)
+	assert.Equal(t, "фото", album.Media[0].(tg.InputMediaPhoto).Caption)
+	assert.IsType(t, tg.PhotoConfig{}, msgs[1])
+}
@@ -0,0 +1,106 @@
+package route
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Route is a node of notification routing tree. Notification goes down to every
+// matching child until a child without Continue matches. If no child matches,
+// node receivers are used.
+type Route struct {
+	Match     map[string]string `koanf:"match"`
+	MatchRe   map[string]string `koanf:"match_re"`
+	Receivers []string          `koanf:"receivers"`
+	Continue  bool              `koanf:"continue"`
+	Routes    []*Route          `koanf:"routes"`
+
+	re map[string]*regexp.Regexp
+}
+
+func (r *Route) Compile() error {
+	r.re = make(map[string]*regexp.Regexp, len(r.MatchRe))
+
+	for k, v := range r.MatchRe {
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex for %s: %w", k, err)
+		}
+
+		r.re[k] = re
+	}
+
+	for _, child := range r.Routes {
+		if err := child.Compile(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Route) Matches(labels map[string]string) bool {
+	for k, v := range r.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	for k, re := range r.re {
+		if !re.MatchString(labels[k]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Routed reports if any child route matches labels, so Find will not fall back to root receivers.
+func (r *Route) Routed(labels map[string]string) bool {
+	for _, child := range r.Routes {
+		if child.Matches(labels) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Find returns receivers for labels without duplicates.
+func (r *Route) Find(labels map[string]string) []string {
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+
+	for _, name := range r.find(labels) {
+		if !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+
+	return res
+}
+
+func (r *Route) find(labels map[string]string) []string {
+	var res []string
+	matched := false
+
+	for _, child := range r.Routes {
+		if !child.Matches(labels) {
+			continue
+		}
+
+		matched = true
+		res = append(res, child.find(labels)...)
+
+		if !child.Continue {
+			break
+		}
+	}
+
+	if !matched {
+		return r.Receivers
+	}
+
+	return res
+}
@@ -0,0 +1,52 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	root := &Route{
+		Receivers: []string{"admin", "family"},
+		Routes: []*Route{
+			{
+				Match:     map[string]string{"source": "frigate", "camera": "kids"},
+				Receivers: []string{"mom"},
+			},
+			{
+				Match:     map[string]string{"source": "vmalert"},
+				MatchRe:   map[string]string{"alertname": "Disk.*"},
+				Receivers: []string{"admin"},
+			},
+			{
+				Match:     map[string]string{"severity": "critical"},
+				Receivers: []string{"admin"},
+				Continue:  true,
+			},
+			{
+				Match:     map[string]string{"source": "vmalert"},
+				Receivers: []string{"family"},
+			},
+		},
+	}
+
+	require.NoError(t, root.Compile())
+
+	tests := []struct {
+		labels    map[string]string
+		receivers []string
+	}{
+		{map[string]string{"source": "frigate", "camera": "kids"}, []string{"mom"}},
+		{map[string]string{"source": "frigate", "camera": "door"}, []string{"admin", "family"}},
+		{map[string]string{"source": "vmalert", "alertname": "DiskFull"}, []string{"admin"}},
+		{map[string]string{"source": "vmalert", "alertname": "HostDown", "severity": "critical"}, []string{"admin", "family"}},
+		{map[string]string{"source": "vmalert", "alertname": "HostDown"}, []string{"family"}},
+		{map[string]string{"source": "grafana", "severity": "critical"}, []string{"admin"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.receivers, root.Find(tt.labels), "labels %v", tt.labels)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/gofiber/fiber/v2"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// telegramNets are networks telegram sends webhook requests from.
+var telegramNets = []string{"149.154.160.0/20", "91.108.4.0/22"}
+
+// Webhook takes telegram updates. It is mounted on api server or, if webhook.listen is set,
+// runs its own server with TLS if webhook.cert and webhook.key are set.
+type Webhook struct {
+	app     *App
+	url     string
+	path    string
+	secret  string
+	allow   []*net.IPNet
+	updates chan tg.Update
+	srv     *fiber.App
+}
+
+func NewWebhook(app *App) (*Webhook, error) {
+	w := &Webhook{
+		app:     app,
+		url:     app.conf.String("webhook.ext"),
+		path:    app.conf.String("webhook.path"),
+		secret:  app.conf.String("webhook.secret"),
+		updates: make(chan tg.Update, 100),
+	}
+
+	// api routes are served from root on the shared server
+	if w.Mounted() && (w.path == "" || w.path == "/") {
+		return nil, fmt.Errorf("webhook.path is required when webhook is served by api server")
+	}
+
+	if w.path == "" {
+		w.path = "/"
+	}
+
+	if w.secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		w.secret = hex.EncodeToString(b)
+	}
+
+	for _, s := range app.conf.Strings("webhook.allow") {
+		nets := []string{s}
+
+		if s == "telegram" {
+			nets = telegramNets
+		}
+
+		for _, n := range nets {
+			_, ipnet, err := net.ParseCIDR(n)
+			if err != nil {
+				return nil, fmt.Errorf("invalid webhook.allow %s: %w", n, err)
+			}
+
+			w.allow = append(w.allow, ipnet)
+		}
+	}
+
+	return w, nil
+}
+
+// Mounted tells if webhook is served by api server.
+func (w *Webhook) Mounted() bool {
+	return w.app.conf.String("webhook.listen") == ""
+}
+
+func (w *Webhook) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !w.allowed(net.ParseIP(c.IP())) {
+			w.app.logger.Warn("webhook request from not allowed address " + c.IP())
+			return c.SendStatus(fiber.StatusForbidden)
+		}
+
+		if !equal(c.Get(secretTokenHeader), w.secret) {
+			w.app.logger.Warn("webhook request with invalid secret token from " + c.IP())
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+
+		var update tg.Update
+
+		if err := json.Unmarshal(c.Body(), &update); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+
+		select {
+		case w.updates <- update:
+			return c.SendStatus(fiber.StatusOK)
+		default:
+			// telegram will retry it
+			return c.SendStatus(fiber.StatusServiceUnavailable)
+		}
+	}
+}
+
+func (w *Webhook) allowed(ip net.IP) bool {
+	if len(w.allow) == 0 {
+		return true
+	}
+
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range w.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Start registers webhook with secret token and starts webhook server if it is not mounted on api server.
+// Certificate from webhook.cert is uploaded, so telegram accepts a self-signed one.
+func (w *Webhook) Start() (tg.UpdatesChannel, error) {
+	params := tg.Params{}
+	params["url"] = w.url
+	params["secret_token"] = w.secret
+	params.AddNonEmpty("max_connections", w.app.conf.String("webhook.max_connections"))
+
+	var err error
+
+	if cert := w.app.conf.String("webhook.cert"); cert != "" {
+		_, err = w.app.bot.UploadFiles("setWebhook", params, []tg.RequestFile{{Name: "certificate", Data: tg.FilePath(cert)}})
+	} else {
+		_, err = w.app.bot.MakeRequest("setWebhook", params)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := w.app.bot.GetWebhookInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.LastErrorDate != 0 {
+		w.app.logger.Warn("last telegram webhook error", "error", info.LastErrorMessage)
+	}
+
+	if w.Mounted() {
+		w.app.logger.Info("webhook is served by api server, path " + w.path)
+		return w.updates, nil
+	}
+
+	listen := w.app.conf.String("webhook.listen")
+	cert, key := w.app.conf.String("webhook.cert"), w.app.conf.String("webhook.key")
+
+	w.srv = fiber.New(fiber.Config{DisableStartupMessage: true})
+	w.srv.Post(w.path, w.Handler())
+
+	w.app.logger.Info(fmt.Sprintf("start webhook listener on %s, path %s", listen, w.path))
+
+	go func() {
+		var err error
+
+		if cert != "" && key != "" {
+			err = w.srv.ListenTLS(listen, cert, key)
+		} else {
+			err = w.srv.Listen(listen)
+		}
+
+		if err != nil {
+			w.app.logger.Error("webhook server error", "error", err)
+		}
+	}()
+
+	return w.updates, nil
+}
+
+// Stop stops own webhook server, mounted webhook is stopped with api server.
+func (w *Webhook) Stop(ctx context.Context) error {
+	if w.srv == nil {
+		return nil
+	}
+
+	return w.srv.ShutdownWithContext(ctx)
+}
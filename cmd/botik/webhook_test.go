@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	conf := NewAppConfig()
+	conf.k.Set("webhook.ext", "https://example.com/hook")
+	conf.k.Set("webhook.path", "/hook")
+	conf.k.Set("webhook.secret", "s3cret")
+
+	app := &App{conf: conf, logger: slog.Default()}
+
+	w, err := NewWebhook(app)
+	require.NoError(t, err)
+	assert.True(t, w.Mounted())
+
+	a := fiber.New()
+	a.Post(w.path, w.Handler())
+
+	send := func(secret string, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		if secret != "" {
+			req.Header.Set(secretTokenHeader, secret)
+		}
+
+		resp, err := a.Test(req)
+		require.NoError(t, err)
+
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("", `{"update_id": 1}`))
+	assert.Equal(t, http.StatusUnauthorized, send("wrong", `{"update_id": 1}`))
+	assert.Equal(t, http.StatusBadRequest, send("s3cret", `{`))
+	assert.Equal(t, http.StatusOK, send("s3cret", `{"update_id": 2, "message": {"text": "hi"}}`))
+
+	update := <-w.updates
+	assert.Equal(t, 2, update.UpdateID)
+	assert.Equal(t, "hi", update.Message.Text)
+}
+
+func TestWebhookAllow(t *testing.T) {
+	conf := NewAppConfig()
+	conf.k.Set("webhook.path", "/hook")
+	conf.k.Set("webhook.allow", []string{"telegram", "10.0.0.0/8"})
+
+	w, err := NewWebhook(&App{conf: conf, logger: slog.Default()})
+	require.NoError(t, err)
+
+	// random secret is generated if it is not set
+	assert.Len(t, w.secret, 64)
+
+	assert.True(t, w.allowed(net.ParseIP("149.154.167.220")))
+	assert.True(t, w.allowed(net.ParseIP("91.108.6.1")))
+	assert.True(t, w.allowed(net.ParseIP("10.1.2.3")))
+	assert.False(t, w.allowed(net.ParseIP("192.168.1.1")))
+	assert.False(t, w.allowed(nil))
+
+	conf.k.Set("webhook.allow", []string{"bad"})
+	_, err = NewWebhook(&App{conf: conf, logger: slog.Default()})
+	assert.Error(t, err)
+}
+
+func TestWebhookPath(t *testing.T) {
+	conf := NewAppConfig()
+
+	// api routes are on the same server
+	_, err := NewWebhook(&App{conf: conf, logger: slog.Default()})
+	assert.Error(t, err)
+
+	conf.k.Set("webhook.path", "/")
+	_, err = NewWebhook(&App{conf: conf, logger: slog.Default()})
+	assert.Error(t, err)
+
+	conf.k.Set("webhook.listen", ":8443")
+	w, err := NewWebhook(&App{conf: conf, logger: slog.Default()})
+	require.NoError(t, err)
+	assert.Equal(t, "/", w.path)
+}
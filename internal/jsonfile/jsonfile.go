@@ -0,0 +1,183 @@
+package jsonfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WriteAtomic writes file with write function to a temporary file and renames it to path,
+// so readers see either old or new content even after a crash.
+func WriteAtomic(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+
+	if err := write(w); err != nil {
+		return fail(err)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		return fail(err)
+	}
+
+	// data must be on disk before rename, otherwise crash can leave empty file
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Save writes v as json file atomically.
+func Save(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return WriteAtomic(path, 0o600, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// Load reads json file to v, it returns false if there is no file.
+func Load(path string, v any) (bool, error) {
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, json.Unmarshal(b, v)
+}
+
+// Log keeps values in a file, one json per line.
+type Log[T any] struct {
+	path string
+	perm os.FileMode
+	mx   sync.Mutex
+}
+
+func NewLog[T any](path string, perm os.FileMode) *Log[T] {
+	return &Log[T]{path: path, perm: perm}
+}
+
+func (l *Log[T]) Append(v T) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	fd, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, l.perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fd.Write(append(b, '\n')); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
+
+// Read calls fn for every value in file order, lines which are not valid json are skipped.
+func (l *Log[T]) Read(fn func(v T)) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	return l.read(fn)
+}
+
+// Keep removes values keep returns false for, the file is rewritten only if something is removed.
+func (l *Log[T]) Keep(keep func(v T) bool) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	res := make([]T, 0)
+	removed := false
+
+	err := l.read(func(v T) {
+		if keep(v) {
+			res = append(res, v)
+		} else {
+			removed = true
+		}
+	})
+
+	if err != nil || !removed {
+		return err
+	}
+
+	return WriteAtomic(l.path, l.perm, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+
+		for _, v := range res {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (l *Log[T]) read(fn func(v T)) error {
+	fd, err := os.Open(l.path)
+
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer fd.Close()
+
+	sc := bufio.NewScanner(fd)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for sc.Scan() {
+		var v T
+
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			continue
+		}
+
+		fn(v)
+	}
+
+	return sc.Err()
+}
@@ -0,0 +1,59 @@
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	N int `json:"n"`
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	var v []item
+	ok, err := Load(path, &v)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, Save(path, []item{{N: 1}, {N: 2}}))
+
+	ok, err = Load(path, &v)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []item{{N: 1}, {N: 2}}, v)
+
+	// no temporary files left
+	files, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	l := NewLog[*item](path, 0o644)
+
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, l.Append(&item{N: i}))
+	}
+
+	read := func() []int {
+		var res []int
+		require.NoError(t, l.Read(func(v *item) { res = append(res, v.N) }))
+		return res
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4}, read())
+
+	require.NoError(t, l.Keep(func(v *item) bool { return v.N%2 == 0 }))
+	assert.Equal(t, []int{2, 4}, read())
+
+	st, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), st.Mode().Perm())
+}
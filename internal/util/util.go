@@ -1,5 +1,12 @@
 package util
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 const TIME_FMT = "02.01.2006 15:04"
 
 func IsInArray(str string, array ...string) bool {
@@ -21,3 +28,21 @@ func HasAllKeys(m map[string]interface{}, keys ...string) bool {
 
 	return true
 }
+
+// ParseDuration is time.ParseDuration with days (1d) and weeks (1w) support.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+
+	for suffix, mul := range map[string]time.Duration{"d": time.Hour * 24, "w": time.Hour * 24 * 7} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %s", s)
+			}
+
+			return time.Duration(v) * mul, nil
+		}
+	}
+
+	return time.ParseDuration(s)
+}